@@ -0,0 +1,238 @@
+// Package alert evaluates rules against the results of polling servers and notifies admins when something is wrong, e.g. a server being full for five minutes or not responding anymore.
+//
+// A rule combines a Condition with how long it has to hold before an alert fires:
+//
+//	engine := alert.New([]alert.Rule{
+//		{Name: "full", When: alert.Full(), For: 5 * time.Minute},
+//		{Name: "down", When: alert.Offline(), Polls: 3},
+//		{Name: "admin", When: alert.Privilege("admin")},
+//		{Name: "private", When: alert.MasterMode("private"), During: alert.Hours{From: 18 * time.Hour, To: 23 * time.Hour}},
+//		{Name: "teamkiller", When: alert.Teamkills(5)},
+//	}, alert.Log(log.Default()), alert.Webhook("https://example.com/hook"))
+//
+//	updates, unsubscribe := poller.Subscribe()
+//	defer unsubscribe()
+//	go engine.Follow(ctx, updates)
+//
+// Notifiers are told when an alert fires and when its condition stops holding again.
+package alert
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// Rule describes a problem to alert about.
+type Rule struct {
+	Name         string        // identifies the rule in alerts
+	When         Condition     // the problem
+	For          time.Duration // how long the condition has to hold before the alert fires; 0 fires right away
+	Polls        int           // how many consecutive polls the condition has to hold for before the alert fires; 0 and 1 fire right away
+	Servers      []string      // the addresses of the servers the rule applies to; all servers if empty
+	During       Hours         // the time of day the rule applies at; all day if empty
+	SkipResolved bool          // don't notify when the condition stops holding, e.g. for rules about events
+}
+
+// applies returns true if the rule is to be evaluated for the server at addr at t
+func (r Rule) applies(addr string, t time.Time) bool {
+	if !r.During.Contains(t) {
+		return false
+	}
+	if len(r.Servers) == 0 {
+		return true
+	}
+	for _, server := range r.Servers {
+		if server == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Hours is a time window repeating every day. From and To are durations since midnight; a window with To before From spans midnight. The zero value spans the whole day.
+type Hours struct {
+	From     time.Duration
+	To       time.Duration
+	Location *time.Location // the time zone From and To are in; time.Local if nil
+}
+
+// Contains returns true if t is within the window.
+func (h Hours) Contains(t time.Time) bool {
+	if h.From == h.To {
+		return true
+	}
+
+	if h.Location != nil {
+		t = t.In(h.Location)
+	} else {
+		t = t.Local()
+	}
+	hour, min, sec := t.Clock()
+	sinceMidnight := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+
+	if h.From < h.To {
+		return h.From <= sinceMidnight && sinceMidnight < h.To
+	}
+	return h.From <= sinceMidnight || sinceMidnight < h.To
+}
+
+// Status is the status of an alert.
+type Status string
+
+// Statuses of alerts
+const (
+	StatusFiring   Status = "firing"   // the rule's condition holds
+	StatusResolved Status = "resolved" // the rule's condition stopped holding
+)
+
+// Alert is sent to notifiers when a rule fires for a server, and again when it is resolved.
+type Alert struct {
+	Rule    string    `json:"rule"`    // the name of the rule
+	Addr    string    `json:"addr"`    // the server's address as host:port
+	Status  Status    `json:"status"`  //
+	Message string    `json:"message"` // what the condition reported when the alert fired
+	Since   time.Time `json:"since"`   // when the condition started to hold
+	Time    time.Time `json:"time"`    // when the alert fired or was resolved
+}
+
+// Engine evaluates rules after every poll of a server and notifies about alerts. It is safe for concurrent use.
+type Engine struct {
+	rules     []Rule
+	notifiers []Notifier
+
+	// ErrorLog receives errors of notifiers. If nil, they are logged using the log package's standard logger.
+	ErrorLog *log.Logger
+
+	mutex  sync.Mutex
+	states map[ruleKey]*ruleState
+}
+
+type ruleKey struct {
+	rule int // index in rules
+	addr string
+}
+
+// what is known about a rule's condition on one server
+type ruleState struct {
+	since   time.Time // when the condition started to hold
+	polls   int       // consecutive polls the condition held for
+	message string
+	firing  bool
+	fired   time.Time // when the alert fired
+}
+
+// New returns an Engine evaluating rules and passing alerts to notifiers.
+func New(rules []Rule, notifiers ...Notifier) *Engine {
+	return &Engine{
+		rules:     rules,
+		notifiers: notifiers,
+		states:    map[ruleKey]*ruleState{},
+	}
+}
+
+// Evaluate evaluates all rules against the result of a poll and returns the alerts that fired or were resolved because of it. It does not notify anyone; use Follow() for that.
+func (e *Engine) Evaluate(update poll.Update) (alerts []Alert) {
+	addr := update.State.Addr
+	now := update.State.LastPoll
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for i, rule := range e.rules {
+		key := ruleKey{i, addr}
+		state := e.states[key]
+
+		holds, message := false, ""
+		if rule.applies(addr, now) {
+			holds, message = rule.When(update)
+		}
+
+		if !holds {
+			if state != nil && state.firing && !rule.SkipResolved {
+				alerts = append(alerts, Alert{Rule: rule.Name, Addr: addr, Status: StatusResolved, Message: state.message, Since: state.since, Time: now})
+			}
+			delete(e.states, key)
+			continue
+		}
+
+		if state == nil {
+			state = &ruleState{since: now}
+			e.states[key] = state
+		}
+		state.polls++
+
+		if state.firing || now.Sub(state.since) < rule.For || state.polls < rule.Polls {
+			continue
+		}
+
+		state.firing = true
+		state.fired = now
+		state.message = message
+		alerts = append(alerts, Alert{Rule: rule.Name, Addr: addr, Status: StatusFiring, Message: message, Since: state.since, Time: now})
+	}
+
+	return
+}
+
+// Firing returns the alerts currently firing, ordered by the time they fired.
+func (e *Engine) Firing() []Alert {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	alerts := []Alert{}
+	for key, state := range e.states {
+		if state.firing {
+			alerts = append(alerts, Alert{Rule: e.rules[key.rule].Name, Addr: key.addr, Status: StatusFiring, Message: state.message, Since: state.since, Time: state.fired})
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].Time.Equal(alerts[j].Time) {
+			return alerts[i].Time.Before(alerts[j].Time)
+		}
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Addr < alerts[j].Addr
+	})
+
+	return alerts
+}
+
+// Follow evaluates the rules for every update received from updates (see (*poll.Poller).Subscribe()) and passes the resulting alerts to all notifiers, until ctx is cancelled or updates is closed.
+func (e *Engine) Follow(ctx context.Context, updates <-chan poll.Update) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			for _, alert := range e.Evaluate(update) {
+				e.notify(ctx, alert)
+			}
+		}
+	}
+}
+
+// passes alert to all notifiers, logging errors
+func (e *Engine) notify(ctx context.Context, alert Alert) {
+	for _, notifier := range e.notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			e.logf("alert: error notifying about %s on %s: %v", alert.Rule, alert.Addr, err)
+		}
+	}
+}
+
+func (e *Engine) logf(format string, args ...interface{}) {
+	if e.ErrorLog != nil {
+		e.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,198 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+const addr = "127.0.0.1:28785"
+
+var start = time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+// returns an update of a successful poll at start+after
+func update(after time.Duration, snap *poll.Snapshot, events ...poll.Event) poll.Update {
+	return poll.Update{
+		State:  poll.State{Addr: addr, Snapshot: snap, LastPoll: start.Add(after)},
+		Events: events,
+	}
+}
+
+// returns an update of a failed poll at start+after
+func failed(after time.Duration) poll.Update {
+	return poll.Update{
+		State: poll.State{Addr: addr, LastPoll: start.Add(after), LastError: "i/o timeout"},
+	}
+}
+
+func snapshot(clients, maxClients int, masterMode string, players ...extinfo.ClientInfo) *poll.Snapshot {
+	snap := &poll.Snapshot{Addr: addr, Clients: map[int]extinfo.ClientInfo{}}
+	snap.BasicInfo.NumberOfClients = clients
+	snap.BasicInfo.MaxNumberOfClients = maxClients
+	snap.BasicInfo.MasterMode = masterMode
+	for _, player := range players {
+		snap.Clients[player.ClientNum] = player
+	}
+	return snap
+}
+
+func player(cn int, name, privilege string, teamkills int) extinfo.ClientInfo {
+	clientInfo := extinfo.ClientInfo{Privilege: privilege}
+	clientInfo.ClientNum = cn
+	clientInfo.Name = name
+	clientInfo.Teamkills = teamkills
+	return clientInfo
+}
+
+// returns "rule status" for each alert
+func summarize(alerts []Alert) []string {
+	summary := []string{}
+	for _, alert := range alerts {
+		summary = append(summary, alert.Rule+" "+string(alert.Status))
+	}
+	return summary
+}
+
+func TestEvaluate(t *testing.T) {
+	engine := New([]Rule{
+		{Name: "full", When: Full(), For: 5 * time.Minute},
+		{Name: "down", When: Offline(), Polls: 3},
+		{Name: "admin", When: Privilege("admin")},
+		{Name: "private", When: MasterMode("private"), During: Hours{From: 18 * time.Hour, To: 22 * time.Hour, Location: time.UTC}},
+		{Name: "teamkiller", When: Teamkills(5)},
+		{Name: "joins", When: Event(poll.EventJoin), SkipResolved: true},
+		{Name: "elsewhere", When: Full(), Servers: []string{"127.0.0.1:10000"}},
+	})
+
+	alice := player(0, "alice", "none", 0)
+	bob := player(1, "bob", "admin", 6)
+
+	steps := []struct {
+		update   poll.Update
+		expected []string
+	}{
+		{update(0, snapshot(2, 2, "open", alice)), []string{}},
+		{update(4*time.Minute, snapshot(2, 2, "open", alice)), []string{}},
+		{update(5*time.Minute, snapshot(2, 2, "open", alice)), []string{"full firing"}},
+		{update(6*time.Minute, snapshot(2, 2, "open", alice)), []string{}},
+		{update(7*time.Minute, snapshot(1, 2, "private", alice, bob), poll.Event{Type: poll.EventJoin, Client: &bob}), []string{"full resolved", "admin firing", "private firing", "teamkiller firing", "joins firing"}},
+		{failed(8 * time.Minute), []string{"admin resolved", "private resolved", "teamkiller resolved"}},
+		{failed(9 * time.Minute), []string{}},
+		{failed(10 * time.Minute), []string{"down firing"}},
+		{update(11*time.Minute, snapshot(1, 2, "open", alice)), []string{"down resolved"}},
+		// 22:00, outside of the hours of the private rule
+		{update(2*time.Hour, snapshot(1, 2, "private", alice)), []string{}},
+	}
+
+	for i, step := range steps {
+		alerts := engine.Evaluate(step.update)
+		if summary := summarize(alerts); !reflect.DeepEqual(summary, step.expected) {
+			t.Errorf("step %d: expected %v, got %v", i, step.expected, summary)
+		}
+	}
+}
+
+func TestAlertDetails(t *testing.T) {
+	engine := New([]Rule{{Name: "teamkiller", When: Teamkills(5), For: time.Minute}})
+
+	bob := player(1, "bob", "none", 6)
+	engine.Evaluate(update(0, snapshot(1, 2, "open", bob)))
+	alerts := engine.Evaluate(update(time.Minute, snapshot(1, 2, "open", bob)))
+
+	expected := []Alert{{Rule: "teamkiller", Addr: addr, Status: StatusFiring, Message: "bob (cn 1) has 6 teamkills", Since: start, Time: start.Add(time.Minute)}}
+	if !reflect.DeepEqual(alerts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, alerts)
+	}
+
+	if firing := engine.Firing(); !reflect.DeepEqual(firing, expected) {
+		t.Errorf("expected %+v to be firing, got %+v", expected, firing)
+	}
+
+	alerts = engine.Evaluate(update(2*time.Minute, snapshot(1, 2, "open", player(1, "bob", "none", 0))))
+	if len(alerts) != 1 || alerts[0].Status != StatusResolved || alerts[0].Message != expected[0].Message {
+		t.Errorf("expected alert to be resolved, got %+v", alerts)
+	}
+	if firing := engine.Firing(); len(firing) != 0 {
+		t.Errorf("expected no alerts firing, got %+v", firing)
+	}
+}
+
+func TestHours(t *testing.T) {
+	evening := Hours{From: 18 * time.Hour, To: 2 * time.Hour, Location: time.UTC}
+
+	tests := []struct {
+		hour     int
+		expected bool
+	}{
+		{17, false},
+		{18, true},
+		{23, true},
+		{1, true},
+		{2, false},
+	}
+
+	for _, test := range tests {
+		if contains := evening.Contains(time.Date(2024, 5, 1, test.hour, 30, 0, 0, time.UTC)); contains != test.expected {
+			t.Errorf("%d:30: expected %v, got %v", test.hour, test.expected, contains)
+		}
+	}
+
+	if !(Hours{}).Contains(start) {
+		t.Error("zero Hours should contain any time")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	received := make(chan Alert, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+		received <- alert
+	}))
+	defer hook.Close()
+
+	logged := &bytes.Buffer{}
+	errorLog := &bytes.Buffer{}
+
+	engine := New([]Rule{{Name: "down", When: Offline()}},
+		Log(log.New(logged, "", 0)),
+		Webhook(hook.URL),
+		Webhook(hook.URL+"/nonexistent\x00"),
+	)
+	engine.ErrorLog = log.New(errorLog, "", 0)
+
+	updates := make(chan poll.Update, 1)
+	updates <- failed(0)
+	close(updates)
+
+	engine.Follow(context.Background(), updates)
+
+	select {
+	case alert := <-received:
+		if alert.Rule != "down" || alert.Status != StatusFiring || alert.Message != "no response: i/o timeout" {
+			t.Errorf("unexpected alert %+v", alert)
+		}
+	default:
+		t.Error("webhook was not called")
+	}
+
+	if expected := "firing: down on " + addr + ": no response: i/o timeout\n"; logged.String() != expected {
+		t.Errorf("expected log %q, got %q", expected, logged)
+	}
+
+	if !strings.Contains(errorLog.String(), "error notifying about down") {
+		t.Errorf("expected error of broken webhook to be logged, got %q", errorLog)
+	}
+}
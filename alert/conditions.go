@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// Condition checks a server for a problem after a poll. It returns whether the problem exists, and if so, a short description of it.
+type Condition func(update poll.Update) (holds bool, message string)
+
+// Offline holds while the server does not respond. Use Rule.Polls to only alert after a couple of failed polls.
+func Offline() Condition {
+	return func(update poll.Update) (bool, string) {
+		if update.State.LastError == "" {
+			return false, ""
+		}
+		return true, "no response: " + update.State.LastError
+	}
+}
+
+// Full holds while all slots of the server are taken.
+func Full() Condition {
+	return online(func(snap *poll.Snapshot) (bool, string) {
+		info := snap.BasicInfo
+		if info.MaxNumberOfClients <= 0 || info.NumberOfClients < info.MaxNumberOfClients {
+			return false, ""
+		}
+		return true, "full: " + strconv.Itoa(info.NumberOfClients) + "/" + strconv.Itoa(info.MaxNumberOfClients) + " clients"
+	})
+}
+
+// MasterMode holds while the server's master mode is one of modes, e.g. "private".
+func MasterMode(modes ...string) Condition {
+	return online(func(snap *poll.Snapshot) (bool, string) {
+		for _, mode := range modes {
+			if snap.BasicInfo.MasterMode == mode {
+				return true, "master mode is " + mode
+			}
+		}
+		return false, ""
+	})
+}
+
+// Privilege holds while a client has one of privileges, e.g. "admin".
+func Privilege(privileges ...string) Condition {
+	return clients(func(clientInfo extinfo.ClientInfo) (bool, string) {
+		for _, privilege := range privileges {
+			if clientInfo.Privilege == privilege {
+				return true, "has " + privilege
+			}
+		}
+		return false, ""
+	})
+}
+
+// Teamkills holds while a player has more than n teamkills in the current game.
+func Teamkills(n int) Condition {
+	return clients(func(clientInfo extinfo.ClientInfo) (bool, string) {
+		if clientInfo.IsBot || clientInfo.Teamkills <= n {
+			return false, ""
+		}
+		return true, "has " + strconv.Itoa(clientInfo.Teamkills) + " teamkills"
+	})
+}
+
+// Event holds for a poll that detected an event of one of types. Since events only hold for one poll, rules using this condition should set SkipResolved.
+func Event(types ...poll.EventType) Condition {
+	return func(update poll.Update) (bool, string) {
+		messages := []string{}
+		for _, event := range update.Events {
+			for _, typ := range types {
+				if event.Type == typ {
+					messages = append(messages, describeEvent(event))
+				}
+			}
+		}
+		if len(messages) == 0 {
+			return false, ""
+		}
+		return true, strings.Join(messages, ", ")
+	}
+}
+
+// returns a condition checking snapshots, which never holds while the server is offline
+func online(check func(snap *poll.Snapshot) (bool, string)) Condition {
+	return func(update poll.Update) (bool, string) {
+		if update.State.LastError != "" || update.State.Snapshot == nil {
+			return false, ""
+		}
+		return check(update.State.Snapshot)
+	}
+}
+
+// returns a condition holding while check holds for at least one client; the message lists all of them
+func clients(check func(clientInfo extinfo.ClientInfo) (bool, string)) Condition {
+	return online(func(snap *poll.Snapshot) (bool, string) {
+		cns := make([]int, 0, len(snap.Clients))
+		for cn := range snap.Clients {
+			cns = append(cns, cn)
+		}
+		sort.Ints(cns)
+
+		messages := []string{}
+		for _, cn := range cns {
+			clientInfo := snap.Clients[cn]
+			if holds, message := check(clientInfo); holds {
+				messages = append(messages, describeClient(clientInfo)+" "+message)
+			}
+		}
+		if len(messages) == 0 {
+			return false, ""
+		}
+		return true, strings.Join(messages, ", ")
+	})
+}
+
+func describeClient(clientInfo extinfo.ClientInfo) string {
+	return clientInfo.Name + " (cn " + strconv.Itoa(clientInfo.ClientNum) + ")"
+}
+
+func describeEvent(event poll.Event) string {
+	description := string(event.Type)
+	if event.Client != nil {
+		description += " " + describeClient(*event.Client)
+	}
+	if event.Team != nil {
+		description += " " + event.Team.Name
+	}
+	if event.Old != "" || event.New != "" {
+		description += ": " + event.Old + " → " + event.New
+	}
+	return description
+}
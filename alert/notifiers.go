@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Notifier tells someone about an alert.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NotifierFunc is a function used as Notifier.
+type NotifierFunc func(ctx context.Context, alert Alert) error
+
+// Notify calls f.
+func (f NotifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// Log returns a Notifier writing alerts to logger, one line each.
+func Log(logger *log.Logger) Notifier {
+	return NotifierFunc(func(_ context.Context, alert Alert) error {
+		logger.Printf("%s: %s on %s: %s", alert.Status, alert.Rule, alert.Addr, alert.Message)
+		return nil
+	})
+}
+
+// time a webhook has to respond, so a hanging one doesn't hold up alerts
+const webhookTimeout = 10 * time.Second
+
+// Webhook returns a Notifier posting alerts as JSON object (see Alert for the fields) to url, e.g. to have a chat bot relay them.
+func Webhook(url string) Notifier {
+	return NotifierFunc(func(ctx context.Context, alert Alert) error {
+		ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		defer cancel()
+
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return errors.New("alert: webhook responded with status " + strconv.Itoa(resp.StatusCode))
+		}
+		return nil
+	})
+}
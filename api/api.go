@@ -0,0 +1,334 @@
+// Package api serves the state of Sauerbraten servers, as kept up to date by a poll.Poller, as JSON over HTTP.
+//
+// Endpoints:
+//
+//	GET /servers                         address, last poll and basic info of every server; ?filter= and ?sort= select and sort servers, see package filter
+//	GET /servers/{addr}                  everything known about the server at addr (host:port)
+//	GET /servers/{addr}/clients          the server's clients, sorted by CN
+//	GET /servers/{addr}/teams            the server's team scores (404 if no team mode is being played)
+//	GET /servers/{addr}/scoreboard       the server's clients arranged like the in-game scoreboard, see extinfo.Scoreboard
+//	GET /servers/{addr}/availability     outages, restarts and availability during the last 30 days, or ?window=24h (only if Options.Uptime is set)
+//	GET /events?server={addr}            server-sent events for the given servers (repeat the parameter for more, omit it for all)
+//
+// The event stream starts with a "state" event per server. After every poll, one event per detected change (see poll.Event) is sent, named after its type (e.g. "join", "teamscore" or "newgame"), followed by a "state" event with the server's new state.
+//
+// Responses carry an ETag and are only encoded again when the server's state changed, so clients polling the API should send If-None-Match.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/filter"
+	"github.com/sauerbraten/extinfo/poll"
+	"github.com/sauerbraten/extinfo/uptime"
+)
+
+// Options configure a Handler.
+type Options struct {
+	AllowOrigin string          // value of the Access-Control-Allow-Origin header, e.g. "*"; CORS is disabled if empty
+	MaxAge      time.Duration   // how long clients may cache responses, usually the poll interval
+	Uptime      *uptime.Monitor // serves /servers/{addr}/availability using this monitor, which has to be fed the same poller's updates; disabled if nil
+}
+
+// Handler serves the API.
+type Handler struct {
+	poller  *poll.Poller
+	options Options
+	mux     *http.ServeMux
+
+	cacheMutex sync.Mutex
+	cache      map[string]cachedResponse // keyed by request path
+}
+
+// a response body, encoded when the state it was built from was at version
+type cachedResponse struct {
+	version string
+	status  int
+	body    []byte
+	etag    string
+}
+
+// New returns a Handler serving the state poller keeps.
+func New(poller *poll.Poller, options Options) *Handler {
+	h := &Handler{
+		poller:  poller,
+		options: options,
+		mux:     http.NewServeMux(),
+		cache:   map[string]cachedResponse{},
+	}
+
+	h.mux.HandleFunc("GET /servers", h.servers)
+	h.mux.HandleFunc("GET /servers/{addr}", h.server)
+	h.mux.HandleFunc("GET /servers/{addr}/clients", h.clients)
+	h.mux.HandleFunc("GET /servers/{addr}/teams", h.teams)
+	h.mux.HandleFunc("GET /servers/{addr}/scoreboard", h.scoreboard)
+	if options.Uptime != nil {
+		h.mux.HandleFunc("GET /servers/{addr}/availability", h.availability)
+	}
+	h.mux.HandleFunc("GET /events", h.events)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.options.AllowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", h.options.AllowOrigin)
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		if h.options.AllowOrigin != "*" {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		// preflight request
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "If-None-Match")
+			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// summary of a server as listed by /servers
+type serverSummary struct {
+	Addr      string             `json:"addr"`
+	LastPoll  time.Time          `json:"lastPoll"`
+	LastError string             `json:"lastError,omitempty"`
+	BasicInfo *extinfo.BasicInfo `json:"basicInfo"`
+}
+
+func (h *Handler) servers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f, err := filter.Parse(query.Get("filter"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody(err.Error()))
+		return
+	}
+	var order *filter.Order
+	if sort := query.Get("sort"); sort != "" {
+		order, err = filter.ParseOrder(sort)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody(err.Error()))
+			return
+		}
+	}
+
+	// the versions of the selected states, in order, determine the response, so different filters can share the cache entry
+	states := filter.Select(h.poller.States(), f, order)
+
+	versions := make([]string, 0, len(states))
+	for _, state := range states {
+		versions = append(versions, stateVersion(state))
+	}
+
+	h.respond(w, r, strings.Join(versions, ","), func() (int, interface{}) {
+		summaries := make([]serverSummary, 0, len(states))
+		for _, state := range states {
+			summary := serverSummary{
+				Addr:      state.Addr,
+				LastPoll:  state.LastPoll,
+				LastError: state.LastError,
+			}
+			if state.Snapshot != nil {
+				summary.BasicInfo = &state.Snapshot.BasicInfo
+			}
+			summaries = append(summaries, summary)
+		}
+		return http.StatusOK, summaries
+	})
+}
+
+func (h *Handler) server(w http.ResponseWriter, r *http.Request) {
+	h.respondWithState(w, r, func(state poll.State) (int, interface{}) {
+		return http.StatusOK, state
+	})
+}
+
+func (h *Handler) clients(w http.ResponseWriter, r *http.Request) {
+	h.respondWithState(w, r, func(state poll.State) (int, interface{}) {
+		if state.Snapshot == nil {
+			return notPolledYet(state)
+		}
+
+		clients := make([]extinfo.ClientInfo, 0, len(state.Snapshot.Clients))
+		for _, clientInfo := range state.Snapshot.Clients {
+			clients = append(clients, clientInfo)
+		}
+		sort.Slice(clients, func(i, j int) bool { return clients[i].ClientNum < clients[j].ClientNum })
+
+		return http.StatusOK, clients
+	})
+}
+
+func (h *Handler) teams(w http.ResponseWriter, r *http.Request) {
+	h.respondWithState(w, r, func(state poll.State) (int, interface{}) {
+		if state.Snapshot == nil {
+			return notPolledYet(state)
+		}
+
+		if state.Snapshot.TeamScores == nil {
+			return http.StatusNotFound, errorBody("server is not running a team mode")
+		}
+
+		return http.StatusOK, state.Snapshot.TeamScores
+	})
+}
+
+func (h *Handler) scoreboard(w http.ResponseWriter, r *http.Request) {
+	h.respondWithState(w, r, func(state poll.State) (int, interface{}) {
+		if state.Snapshot == nil {
+			return notPolledYet(state)
+		}
+
+		snap := state.Snapshot
+		return http.StatusOK, extinfo.NewScoreboard(snap.BasicInfo, snap.TeamScores, snap.Clients)
+	})
+}
+
+// default period /servers/{addr}/availability reports on
+const defaultAvailabilityWindow = 30 * 24 * time.Hour
+
+// availability report as served by /servers/{addr}/availability
+type availability struct {
+	uptime.Report
+	Start *time.Time `json:"start,omitempty"` // when the server's current process was started, estimated from its uptime
+}
+
+// not cached like the other responses, since the report changes with the query and over time
+func (h *Handler) availability(w http.ResponseWriter, r *http.Request) {
+	addr := r.PathValue("addr")
+	if _, ok := h.poller.State(addr); !ok {
+		writeJSON(w, http.StatusNotFound, errorBody("unknown server "+strconv.Quote(addr)))
+		return
+	}
+
+	window := defaultAvailabilityWindow
+	if param := r.URL.Query().Get("window"); param != "" {
+		var err error
+		window, err = time.ParseDuration(param)
+		if err != nil || window <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorBody("invalid window "+strconv.Quote(param)))
+			return
+		}
+	}
+
+	now := time.Now()
+	report, ok := h.options.Uptime.Report(addr, now.Add(-window), now)
+	if !ok {
+		writeJSON(w, http.StatusServiceUnavailable, errorBody("server was not polled during the window yet"))
+		return
+	}
+
+	result := availability{Report: report}
+	if start, ok := h.options.Uptime.Start(addr); ok {
+		result.Start = &start
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// looks up the state of the server given in the URL and responds with what build returns for it
+func (h *Handler) respondWithState(w http.ResponseWriter, r *http.Request, build func(poll.State) (int, interface{})) {
+	addr := r.PathValue("addr")
+	state, ok := h.poller.State(addr)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorBody("unknown server "+strconv.Quote(addr)))
+		return
+	}
+
+	h.respond(w, r, stateVersion(state), func() (int, interface{}) {
+		return build(state)
+	})
+}
+
+// responds with the cached response for the request's path if it was built from the same version of the state, or builds, encodes and caches a new one
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, version string, build func() (int, interface{})) {
+	key := r.URL.Path
+
+	h.cacheMutex.Lock()
+	cached, ok := h.cache[key]
+	h.cacheMutex.Unlock()
+
+	if !ok || cached.version != version {
+		status, v := build()
+		body, err := json.Marshal(v)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorBody(err.Error()))
+			return
+		}
+
+		hash := fnv.New64a()
+		hash.Write(body)
+
+		cached = cachedResponse{
+			version: version,
+			status:  status,
+			body:    body,
+			etag:    fmt.Sprintf(`"%x"`, hash.Sum64()),
+		}
+
+		h.cacheMutex.Lock()
+		h.cache[key] = cached
+		h.cacheMutex.Unlock()
+	}
+
+	w.Header().Set("ETag", cached.etag)
+	if h.options.MaxAge > 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.options.MaxAge.Seconds())))
+	}
+
+	if cached.status == http.StatusOK && etagMatches(r.Header.Get("If-None-Match"), cached.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(cached.status)
+	w.Write(cached.body)
+}
+
+// reports whether the If-None-Match header value matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// identifies the state of a server; changes whenever the server was polled
+func stateVersion(state poll.State) string {
+	return state.Addr + "@" + strconv.FormatInt(state.LastPoll.UnixNano(), 10)
+}
+
+func notPolledYet(state poll.State) (int, interface{}) {
+	message := "server was not polled successfully yet"
+	if state.LastError != "" {
+		message += ": " + state.LastError
+	}
+	return http.StatusServiceUnavailable, errorBody(message)
+}
+
+func errorBody(message string) interface{} {
+	return map[string]string{"error": message}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+	"github.com/sauerbraten/extinfo/uptime"
+)
+
+func startPoller(t *testing.T, states ...fakeserver.State) (*poll.Poller, []string) {
+	t.Helper()
+
+	servers, addrs := []*extinfo.Server{}, []string{}
+	for _, state := range states {
+		fake, err := fakeserver.Start(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { fake.Close() })
+
+		s, err := extinfo.NewServer(fake.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := fake.Addr()
+		servers = append(servers, s)
+		addrs = append(addrs, addr.String())
+	}
+
+	p := poll.New(time.Minute, servers...)
+	p.PollAll()
+	return p, addrs
+}
+
+func get(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestEndpoints(t *testing.T) {
+	notTeamMode := fakeserver.DefaultState()
+	notTeamMode.TeamMode = false
+	notTeamMode.GameMode = 3
+
+	p, addrs := startPoller(t, fakeserver.DefaultState(), notTeamMode)
+	h := New(p, Options{})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/servers", http.StatusOK},
+		{"/servers/" + addrs[0], http.StatusOK},
+		{"/servers/" + addrs[0] + "/clients", http.StatusOK},
+		{"/servers/" + addrs[0] + "/teams", http.StatusOK},
+		{"/servers/" + addrs[1] + "/teams", http.StatusNotFound},
+		{"/servers/" + addrs[0] + "/scoreboard", http.StatusOK},
+		{"/servers/127.0.0.1:1", http.StatusNotFound},
+		{"/servers/127.0.0.1:1/clients", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := get(h, test.path, nil)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", test.path, test.status, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: unexpected content type %q", test.path, ct)
+		}
+	}
+
+	summaries := []serverSummary{}
+	if err := json.Unmarshal(get(h, "/servers", nil).Body.Bytes(), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].BasicInfo == nil {
+		t.Errorf("unexpected server list %+v", summaries)
+	}
+
+	clients := []extinfo.ClientInfo{}
+	if err := json.Unmarshal(get(h, "/servers/"+addrs[0]+"/clients", nil).Body.Bytes(), &clients); err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 4 || clients[0].Name != "alice" || !clients[3].IsBot {
+		t.Errorf("unexpected clients %+v", clients)
+	}
+}
+
+func TestETag(t *testing.T) {
+	p, addrs := startPoller(t, fakeserver.DefaultState())
+	h := New(p, Options{MaxAge: 5 * time.Second})
+	path := "/servers/" + addrs[0] + "/clients"
+
+	w := get(h, path, nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=5" {
+		t.Errorf("unexpected Cache-Control %q", cc)
+	}
+
+	w = get(h, path, http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected empty 304 response, got %d: %s", w.Code, w.Body)
+	}
+
+	// clients didn't change, so the newly encoded response has the same ETag
+	p.PollAll()
+	w = get(h, path, http.Header{"If-None-Match": {`"other", ` + etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 after unchanged poll, got %d", w.Code)
+	}
+
+	w = get(h, path, http.Header{"If-None-Match": {`"other"`}})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for stale ETag, got %d", w.Code)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	p, _ := startPoller(t, fakeserver.DefaultState())
+
+	w := get(New(p, Options{}), "/servers", nil)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("CORS header set although disabled: %q", origin)
+	}
+
+	h := New(p, Options{AllowOrigin: "*"})
+	w = get(h, "/servers", nil)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", origin)
+	}
+
+	r := httptest.NewRequest(http.MethodOptions, "/servers", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("unexpected preflight response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	p, addrs := startPoller(t, fakeserver.DefaultState())
+	monitor := uptime.New()
+	h := New(p, Options{Uptime: monitor})
+	path := "/servers/" + addrs[0] + "/availability"
+
+	if w := get(New(p, Options{}), path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without monitor, got %d", w.Code)
+	}
+
+	if w := get(h, path, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the monitor observed the server, got %d: %s", w.Code, w.Body)
+	}
+
+	now := time.Now()
+	monitor.Observe(addrs[0], now.Add(-2*time.Minute), 600, nil)
+	monitor.Observe(addrs[0], now.Add(-time.Minute), 0, errors.New("i/o timeout"))
+	monitor.Observe(addrs[0], now, 720, nil)
+
+	w := get(h, path+"?window=24h", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	result := availability{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Outages) != 1 || result.Availability < 49 || result.Availability > 51 || result.Start == nil {
+		t.Errorf("unexpected availability %+v", result)
+	}
+
+	for _, window := range []string{"abc", "-1h", "0"} {
+		if w := get(h, path+"?window="+window, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("window %q: expected 400, got %d", window, w.Code)
+		}
+	}
+
+	if w := get(h, "/servers/127.0.0.1:1/availability", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown server, got %d", w.Code)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	empty := fakeserver.DefaultState()
+	empty.Clients = nil
+	empty.NumberOfClients = 0
+	empty.Map = "ot"
+
+	p, addrs := startPoller(t, fakeserver.DefaultState(), empty)
+	h := New(p, Options{})
+
+	unfiltered := []string{addrs[0], addrs[1]}
+	sort.Strings(unfiltered)
+
+	tests := []struct {
+		query    string
+		status   int
+		expected []string
+	}{
+		{"", http.StatusOK, unfiltered},
+		{"?filter=map~%5Eot", http.StatusOK, []string{addrs[1]}},
+		{"?filter=!empty", http.StatusOK, []string{addrs[0]}},
+		{"?sort=-map", http.StatusOK, []string{addrs[1], addrs[0]}},
+		{"?filter=online&sort=map", http.StatusOK, []string{addrs[0], addrs[1]}},
+		{"?filter=crowded", http.StatusBadRequest, nil},
+		{"?sort=size", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		w := get(h, "/servers"+test.query, nil)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", test.query, test.status, w.Code, w.Body)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		summaries := []serverSummary{}
+		if err := json.Unmarshal(w.Body.Bytes(), &summaries); err != nil {
+			t.Fatal(err)
+		}
+		selected := []string{}
+		for _, summary := range summaries {
+			selected = append(selected, summary.Addr)
+		}
+		if !reflect.DeepEqual(selected, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, selected)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// how often a comment is sent on otherwise idle event streams, so proxies don't close them
+const keepAliveInterval = 30 * time.Second
+
+// events streams updates of the servers given in the server query parameter (all servers if there is none) as server-sent events.
+// Every poll results in a "state" event with the server's new state, preceded by one event per change detected, named after the change's type (e.g. "join").
+// When the client didn't keep up and polls were dropped, a "lagged" event tells how many, followed by the current state of every server streamed, so the client can start over from there.
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorBody("streaming not supported"))
+		return
+	}
+
+	// subscribe first, so nothing happening while the initial states are sent is missed
+	updates, unsubscribe := h.poller.Subscribe()
+	defer unsubscribe()
+
+	initial := []poll.State{}
+	wanted := map[string]bool{}
+	if addrs := r.URL.Query()["server"]; len(addrs) > 0 {
+		for _, addr := range addrs {
+			state, ok := h.poller.State(addr)
+			if !ok {
+				writeJSON(w, http.StatusNotFound, errorBody("unknown server "+strconv.Quote(addr)))
+				return
+			}
+			if !wanted[addr] {
+				initial = append(initial, state)
+			}
+			wanted[addr] = true
+		}
+	} else {
+		initial = h.poller.States()
+		for _, state := range initial {
+			wanted[state.Addr] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// when the state of each server that was sent last was polled, so updates queued before are not sent again
+	sent := map[string]time.Time{}
+	for _, state := range initial {
+		writeEvent(w, "state", state)
+		sent[state.Addr] = state.LastPoll
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if update.Dropped > 0 {
+				writeEvent(w, "lagged", map[string]int{"dropped": update.Dropped})
+				for _, state := range initial {
+					state, _ = h.poller.State(state.Addr)
+					writeEvent(w, "state", state)
+					sent[state.Addr] = state.LastPoll
+				}
+			}
+			if wanted[update.State.Addr] && update.State.LastPoll.After(sent[update.State.Addr]) {
+				for _, event := range update.Events {
+					writeEvent(w, string(event.Type), event)
+				}
+				writeEvent(w, "state", update.State)
+				sent[update.State.Addr] = update.State.LastPoll
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writes v as JSON-encoded server-sent event called name
+func writeEvent(w http.ResponseWriter, name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
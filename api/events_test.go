@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// reads the names of server-sent events from r until n events were read
+func readEventNames(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+
+	names := []string{}
+	for len(names) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return names
+}
+
+func TestEvents(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fake.Addr()
+
+	p := poll.New(time.Minute, s)
+	p.PollAll()
+
+	srv := httptest.NewServer(New(p, Options{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events?server=127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown server, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/events?server=" + addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	if names := readEventNames(t, r, 1); names[0] != "state" {
+		t.Fatalf("expected initial state, got %v", names)
+	}
+
+	state := fakeserver.DefaultState()
+	state.Teams[1].Score++
+	state.Clients = append(state.Clients, fakeserver.Client{CN: 12, Name: "dave", Team: "evil", State: 5, IP: net.IPv4(10, 0, 3, 0)})
+	fake.SetState(state)
+	p.PollAll()
+
+	names := readEventNames(t, r, 3)
+	expected := []string{"join", "teamscore", "state"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %v, got %v", expected, names)
+	}
+}
+
+// streams the response through a pipe, so the handler blocks until the test reads what it wrote
+type pipeResponseWriter struct {
+	header http.Header
+	*io.PipeWriter
+}
+
+func (w pipeResponseWriter) Header() http.Header { return w.header }
+func (w pipeResponseWriter) WriteHeader(int)     {}
+func (w pipeResponseWriter) Flush()              {}
+
+func TestEventsLagged(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := poll.New(time.Minute, s)
+	p.PollAll()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		New(p, Options{}).ServeHTTP(pipeResponseWriter{http.Header{}, pw}, httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		pr.Close()
+		<-done
+	}()
+
+	// large enough to take every event in one read, so the handler only blocks when the test stops reading
+	r := bufio.NewReaderSize(pr, 1<<16)
+	if names := readEventNames(t, r, 1); names[0] != "state" {
+		t.Fatalf("expected initial state, got %v", names)
+	}
+
+	// the handler blocks writing the first update, while the subscription's buffer fills up and overflows
+	buffered := 4*1 + 16
+	for range 1 + buffered + 5 {
+		p.PollAll()
+	}
+	names := readEventNames(t, r, 1+buffered)
+	if strings.Count(strings.Join(names, ","), "state") != 1+buffered {
+		t.Fatalf("expected %d states, got %v", 1+buffered, names)
+	}
+
+	// the next update reports the drops, followed by the current state, which isn't sent again for the update itself
+	p.PollAll()
+	names = readEventNames(t, r, 2)
+	if expected := []string{"lagged", "state"}; strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %v, got %v", expected, names)
+	}
+
+	state := fakeserver.DefaultState()
+	state.Clients = append(state.Clients, fakeserver.Client{CN: 12, Name: "dave", Team: "evil", State: 5, IP: net.IPv4(10, 0, 3, 0)})
+	fake.SetState(state)
+	p.PollAll()
+	names = readEventNames(t, r, 2)
+	if expected := []string{"join", "state"}; strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %v, got %v", expected, names)
+	}
+}
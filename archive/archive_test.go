@@ -0,0 +1,226 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+func querySnapshot(t *testing.T) *poll.Snapshot {
+	t.Helper()
+
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := poll.Query(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as it comes back from JSON
+	snap.Time = snap.Time.UTC().Round(0)
+	return snap
+}
+
+func readAll(t *testing.T, dir string) []Record {
+	t.Helper()
+
+	records := []Record{}
+	err := ReadDir(dir, func(rec Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestRoundTrip(t *testing.T) {
+	snap := querySnapshot(t)
+	failed := Record{Time: snap.Time.Add(time.Second), Addr: snap.Addr, Error: "i/o timeout"}
+
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+
+		w, err := NewWriter(Options{Dir: dir, MaxSize: 1, Gzip: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		written := []Record{NewSnapshotRecord(snap), failed, NewSnapshotRecord(snap)}
+		for _, rec := range written {
+			if err := w.Write(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		files, err := Files(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != len(written) {
+			t.Errorf("gzip=%t: expected a file per record, got %v", compress, files)
+		}
+
+		read := readAll(t, dir)
+		if !reflect.DeepEqual(read, written) {
+			t.Errorf("gzip=%t: records changed:\nwritten %+v\nread    %+v", compress, written, read)
+		}
+
+		// the raw ints are restored
+		if c := read[0].Snapshot.Clients[10]; c.ClientInfoRaw.Privilege != 1 || c.Privilege != "master" {
+			t.Errorf("gzip=%t: unexpected client %+v", compress, c)
+		}
+		if read[0].Snapshot.BasicInfo.BasicInfoRaw.GameMode != 12 {
+			t.Errorf("gzip=%t: unexpected basic info %+v", compress, read[0].Snapshot.BasicInfo)
+		}
+	}
+}
+
+func TestCrash(t *testing.T) {
+	snap := querySnapshot(t)
+
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+
+		w, err := NewWriter(Options{Dir: dir, Gzip: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := w.Write(NewSnapshotRecord(snap)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the writer is never closed, and the last line is incomplete
+		if !compress {
+			files, _ := Files(dir)
+			f, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(`{"time":"2006-01-02T`)
+			f.Close()
+		}
+
+		if n := len(readAll(t, dir)); n != 2 {
+			t.Errorf("gzip=%t: expected 2 records, got %d", compress, n)
+		}
+	}
+}
+
+func TestFollowUnencodable(t *testing.T) {
+	snap := querySnapshot(t)
+
+	// a mod's extension that encoding/json can't handle
+	bad := *snap
+	bad.Clients = map[int]extinfo.ClientInfo{}
+	for cn, clientInfo := range snap.Clients {
+		clientInfo.Extension = func() {}
+		bad.Clients[cn] = clientInfo
+	}
+
+	dir := t.TempDir()
+	errorLog := &bytes.Buffer{}
+	w, err := NewWriter(Options{Dir: dir, ErrorLog: log.New(errorLog, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	updates := make(chan poll.Update, 3)
+	for _, s := range []*poll.Snapshot{snap, &bad, snap} {
+		updates <- poll.Update{State: poll.State{Addr: s.Addr, Snapshot: s, LastPoll: s.Time}}
+	}
+	close(updates)
+
+	if err := w.Follow(context.Background(), updates); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readAll(t, dir)); n != 2 {
+		t.Errorf("expected 2 records, got %d", n)
+	}
+	if !strings.Contains(errorLog.String(), "archive: encoding record of "+snap.Addr) {
+		t.Errorf("unexpected error log %q", errorLog.String())
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(Options{Dir: dir, Prefix: "test", MaxAge: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := w.Write(Record{Time: time.Now(), Addr: "127.0.0.1:28785", Error: "timeout"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "test-*"+FileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files, got %v", files)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	old := filepath.Join(dir, "test-20240101T000000.000000000Z"+FileExtension)
+	other := filepath.Join(dir, "other-20240101T000000.000000000Z"+FileExtension)
+	for _, path := range []string{old, other} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, time.Now(), time.Now().Add(-48*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewWriter(Options{Dir: dir, Prefix: "test", Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Write(Record{Time: time.Now(), Addr: "127.0.0.1:28785", Error: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0] != other {
+		t.Errorf("expected only the expired file with the writer's prefix to be deleted, got %v", files)
+	}
+}
@@ -0,0 +1,147 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reader reads records from an archive.
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer // nil if the underlying reader is not ours to close
+	line   int
+}
+
+// NewReader returns a Reader reading uncompressed records from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Open opens the archive file at path, which is decompressed if its name ends in GzipFileExtension.
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, GzipFileExtension) {
+		r := NewReader(file)
+		r.closer = file
+		return r, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, io.EOF) {
+			// a compressed file was created, but nothing was written to it
+			r := NewReader(strings.NewReader(""))
+			return r, nil
+		}
+		return nil, errors.New("archive: " + path + ": " + err.Error())
+	}
+
+	r := NewReader(gz)
+	r.closer = file
+	return r, nil
+}
+
+// Next returns the next record, or io.EOF when there are no more. An incomplete last line, as left by a crash during writing, is skipped.
+// A line that can't be decoded results in an error mentioning its line number; reading can continue after it.
+func (r *Reader) Next() (rec Record, err error) {
+	line, err := r.r.ReadBytes('\n')
+	if err != nil {
+		// io.EOF before a newline means an incomplete record; io.ErrUnexpectedEOF comes from gzip streams cut off by a crash
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return
+	}
+	r.line++
+
+	err = json.Unmarshal(line, &rec)
+	if err != nil {
+		err = errors.New("archive: line " + strconv.Itoa(r.line) + ": " + err.Error())
+		return
+	}
+
+	rec.restoreRaw()
+	return
+}
+
+// Close closes the file opened by Open. It does nothing for Readers created using NewReader.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Files returns the paths of all archive files in dir, oldest first.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && (strings.HasSuffix(name, FileExtension) || strings.HasSuffix(name, GzipFileExtension)) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+
+	// file names start with a common prefix followed by the creation time
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// ReadDir calls fn for every record in the archive files in dir, oldest first, until fn returns an error, which is then returned.
+func ReadDir(dir string, fn func(Record) error) error {
+	paths, err := Files(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		err = readFile(path, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readFile(path string, fn func(Record) error) error {
+	r, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+
+		err = fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+}
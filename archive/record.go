@@ -0,0 +1,87 @@
+// Package archive writes poll results to append-only, newline-delimited JSON files and reads them back.
+//
+// Every line of an archive is one Record. Files are rotated by size and age, and can optionally be gzip-compressed; Open and ReadDir handle both.
+package archive
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// Record is the result of one poll of a server.
+type Record struct {
+	Time     time.Time      `json:"time"`               // when the server was polled
+	Addr     string         `json:"addr"`               // the server's address as host:port
+	Error    string         `json:"error,omitempty"`    // why the poll failed, "" if it succeeded
+	Snapshot *poll.Snapshot `json:"snapshot,omitempty"` // the parsed state of the server, nil if the poll failed
+	Raw      *Raw           `json:"raw,omitempty"`      // the raw state of the server, nil if the poll failed
+}
+
+// Raw holds the raw fields of a snapshot, i.e. the ints the parsed fields were translated from. The parsed types embed their raw counterparts, but encoding/json drops the embedded fields that share a name with a parsed one, so they are stored separately.
+type Raw struct {
+	BasicInfo  extinfo.BasicInfoRaw    `json:"basicInfo"`
+	TeamScores *extinfo.TeamScoresRaw  `json:"teamScores,omitempty"`
+	Clients    []extinfo.ClientInfoRaw `json:"clients"` // sorted by CN
+}
+
+// NewRecord returns the record of the last poll of a server, as kept by a poll.Poller.
+func NewRecord(state poll.State) Record {
+	if state.LastError != "" || state.Snapshot == nil {
+		return Record{
+			Time:  state.LastPoll,
+			Addr:  state.Addr,
+			Error: state.LastError,
+		}
+	}
+
+	return NewSnapshotRecord(state.Snapshot)
+}
+
+// NewSnapshotRecord returns the record of a successful poll resulting in snap.
+func NewSnapshotRecord(snap *poll.Snapshot) Record {
+	raw := &Raw{
+		BasicInfo: snap.BasicInfo.BasicInfoRaw,
+		Clients:   make([]extinfo.ClientInfoRaw, 0, len(snap.Clients)),
+	}
+
+	if snap.TeamScores != nil {
+		raw.TeamScores = &snap.TeamScores.TeamScoresRaw
+	}
+
+	for _, clientInfo := range snap.Clients {
+		raw.Clients = append(raw.Clients, clientInfo.ClientInfoRaw)
+	}
+	sort.Slice(raw.Clients, func(i, j int) bool { return raw.Clients[i].ClientNum < raw.Clients[j].ClientNum })
+
+	return Record{
+		Time:     snap.Time,
+		Addr:     snap.Addr,
+		Snapshot: snap,
+		Raw:      raw,
+	}
+}
+
+// puts the raw fields back into the decoded snapshot, so it equals the one the record was created from
+func (r *Record) restoreRaw() {
+	if r.Snapshot == nil || r.Raw == nil {
+		return
+	}
+
+	r.Snapshot.BasicInfo.BasicInfoRaw = r.Raw.BasicInfo
+
+	if r.Snapshot.TeamScores != nil && r.Raw.TeamScores != nil {
+		r.Snapshot.TeamScores.TeamScoresRaw = *r.Raw.TeamScores
+	}
+
+	for _, clientInfoRaw := range r.Raw.Clients {
+		clientInfo, ok := r.Snapshot.Clients[clientInfoRaw.ClientNum]
+		if !ok {
+			continue
+		}
+		clientInfo.ClientInfoRaw = clientInfoRaw
+		r.Snapshot.Clients[clientInfoRaw.ClientNum] = clientInfo
+	}
+}
@@ -0,0 +1,218 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// file name extensions of archives
+const (
+	FileExtension     = ".ndjson"
+	GzipFileExtension = ".ndjson.gz"
+)
+
+// Options configure a Writer.
+type Options struct {
+	Dir     string        // directory to create archive files in
+	Prefix  string        // beginning of archive file names, followed by the time the file was created; defaults to "extinfo"
+	MaxSize int64         // start a new file once the current one holds this many (uncompressed) bytes; 0 for no limit
+	MaxAge  time.Duration // start a new file once the current one is this old; 0 for no limit
+	Gzip    bool          // compress files using gzip
+	// delete files (with the same prefix) last written to longer ago than this whenever a new file is started; 0 keeps all files
+	Retention time.Duration
+	// ErrorLog receives the errors of records Follow() skips because they can't be encoded. If nil, they are logged using the log package's standard logger.
+	ErrorLog *log.Logger
+}
+
+// Writer appends records to archive files in a directory, starting a new file whenever the current one grows too large or too old.
+// It is safe for concurrent use.
+type Writer struct {
+	options Options
+
+	mutex   sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer // nil if not compressing
+	out     io.Writer    // gz or file
+	size    int64        // uncompressed bytes written to the current file
+	created time.Time    // when the current file was created
+}
+
+// NewWriter returns a Writer as configured by options. The first file is created when the first record is written.
+func NewWriter(options Options) (*Writer, error) {
+	if options.Prefix == "" {
+		options.Prefix = "extinfo"
+	}
+
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Writer{options: options}, nil
+}
+
+// Write appends rec to the current file as one line of JSON. Compressed files are flushed after every record, so a crash loses at most the record being written.
+func (w *Writer) Write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return encodeError{errors.New("archive: encoding record of " + rec.Addr + ": " + err.Error())}
+	}
+	line = append(line, '\n')
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file != nil && w.needsRotation(len(line)) {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.out.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if w.gz != nil {
+		return w.gz.Flush()
+	}
+	return nil
+}
+
+// Follow writes a record for every update received, until updates is closed, ctx is cancelled or writing to a file fails. Records that can't be encoded are logged to Options.ErrorLog and skipped. updates usually comes from (*poll.Poller).Subscribe(), using poll.Lossless(), so no poll result is missed when writing falls behind.
+func (w *Writer) Follow(ctx context.Context, updates <-chan poll.Update) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			err := w.Write(NewRecord(update.State))
+			if errors.As(err, &encodeError{}) {
+				w.logf("%v", err)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// returned by Write() when a record can't be encoded, which leaves the file untouched
+type encodeError struct {
+	error
+}
+
+func (w *Writer) logf(format string, args ...interface{}) {
+	if w.options.ErrorLog != nil {
+		w.options.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.closeFile()
+}
+
+// reports whether a new file has to be started before writing n more bytes
+func (w *Writer) needsRotation(n int) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.options.MaxSize > 0 && w.size+int64(n) > w.options.MaxSize {
+		return true
+	}
+	return w.options.MaxAge > 0 && time.Since(w.created) >= w.options.MaxAge
+}
+
+func (w *Writer) openFile() error {
+	w.created = time.Now()
+
+	name := w.options.Prefix + "-" + w.created.UTC().Format("20060102T150405.000000000Z")
+	if w.options.Gzip {
+		name += GzipFileExtension
+	} else {
+		name += FileExtension
+	}
+
+	file, err := os.OpenFile(filepath.Join(w.options.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w.file, w.out, w.size = file, file, 0
+	if w.options.Gzip {
+		w.gz = gzip.NewWriter(file)
+		w.out = w.gz
+	}
+
+	if w.options.Retention > 0 {
+		return w.deleteExpired()
+	}
+	return nil
+}
+
+// deletes the files older than the retention period
+func (w *Writer) deleteExpired() error {
+	paths, err := Files(w.options.Dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := w.created.Add(-w.options.Retention)
+	for _, path := range paths {
+		if !strings.HasPrefix(filepath.Base(path), w.options.Prefix+"-") || path == w.file.Name() {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) closeFile() error {
+	var err error
+	if w.gz != nil {
+		err = w.gz.Close()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	w.file, w.gz, w.out = nil, nil, nil
+	return err
+}
@@ -8,16 +8,17 @@ import (
 
 // BasicInfoRaw contains the information sent back from the server in their raw form, i.e. no translation from ints to strings, even if possible.
 type BasicInfoRaw struct {
-	NumberOfClients    int    `json:"numberOfClients"`    // the number of clients currently connected to the server (players and spectators)
-	ProtocolVersion    int    `json:"protocolVersion"`    // version number of the protocol in use by the server
-	GameMode           int    `json:"gameMode"`           // current game mode
-	SecsLeft           int    `json:"secsLeft"`           // the time left until intermission in seconds
-	MaxNumberOfClients int    `json:"maxNumberOfClients"` // the maximum number of clients the server allows
-	MasterMode         int    `json:"masterMode"`         // the current master mode of the server
-	Paused             bool   `json:"paused"`             // wether the game is paused or not
-	GameSpeed          int    `json:"gameSpeed"`          // the gamespeed
-	Map                string `json:"map"`                // current map
-	Description        string `json:"description"`        // server description
+	NumberOfClients    int       `json:"numberOfClients"`     // the number of clients currently connected to the server (players and spectators)
+	ProtocolVersion    int       `json:"protocolVersion"`     // version number of the protocol in use by the server
+	GameMode           int       `json:"gameMode"`            // current game mode
+	SecsLeft           int       `json:"secsLeft"`            // the time left until intermission in seconds
+	MaxNumberOfClients int       `json:"maxNumberOfClients"`  // the maximum number of clients the server allows
+	MasterMode         int       `json:"masterMode"`          // the current master mode of the server
+	Paused             bool      `json:"paused"`              // wether the game is paused or not
+	GameSpeed          int       `json:"gameSpeed"`           // the gamespeed
+	Map                string    `json:"map"`                 // current map
+	Description        string    `json:"description"`         // server description
+	Extension          Extension `json:"extension,omitempty"` // mod-specific data following the standard fields, see RegisterExtensionParsers()
 }
 
 // BasicInfo contains the parsed information sent back from the server, i.e. game mode and master mode are translated into human readable strings.
@@ -29,12 +30,15 @@ type BasicInfo struct {
 
 // GetBasicInfoRaw queries a Sauerbraten server at addr on port and returns the raw response or an error in case something went wrong. Raw response means that the int values sent as game mode and master mode are NOT translated into the human readable name.
 func (s *Server) GetBasicInfoRaw() (basicInfoRaw BasicInfoRaw, err error) {
-	var response *cubecode.Packet
-	response, err = s.queryServer(buildRequest(InfoTypeBasic, 0, 0))
-	if err != nil {
+	err = s.queryServer(buildRequest(InfoTypeBasic, 0, 0), func(response *cubecode.Packet) (err error) {
+		basicInfoRaw, err = s.parseBasicInfo(response)
 		return
-	}
+	})
+	return
+}
 
+// parses the response to a basic info request, starting after the replayed request
+func (s *Server) parseBasicInfo(response *cubecode.Packet) (basicInfoRaw BasicInfoRaw, err error) {
 	basicInfoRaw.NumberOfClients, err = response.ReadInt()
 	if err != nil {
 		err = errors.New("extinfo: error reading number of connected clients: " + err.Error())
@@ -104,25 +108,46 @@ func (s *Server) GetBasicInfoRaw() (basicInfoRaw BasicInfoRaw, err error) {
 		basicInfoRaw.GameSpeed = 100
 	}
 
-	basicInfoRaw.Map, err = response.ReadString()
+	basicInfoRaw.Map, err = readString(response, "")
 	if err != nil {
 		err = errors.New("extinfo: error reading map name: " + err.Error())
 		return
 	}
 
-	basicInfoRaw.Description, err = response.ReadString()
+	basicInfoRaw.Description, err = readString(response, "")
 	if err != nil {
 		err = errors.New("extinfo: error reading server description: " + err.Error())
 		return
 	}
 
+	basicInfoRaw.Extension, err = s.parseExtension(response, func(p ExtensionParsers) ExtensionParser { return p.BasicInfo })
+
 	return
 }
 
+// BasicInfoOption changes how GetBasicInfo() parses the server's response.
+type BasicInfoOption func(*basicInfoOptions)
+
+type basicInfoOptions struct {
+	keepColorCodes bool
+}
+
+// KeepColorCodes sets whether color codes like "\f3" are kept in the map name and description. By default, they are removed, along with surrounding whitespace. Package github.com/sauerbraten/extinfo/colors renders them for terminals and web pages.
+func KeepColorCodes(keep bool) BasicInfoOption {
+	return func(o *basicInfoOptions) {
+		o.keepColorCodes = keep
+	}
+}
+
 // GetBasicInfo queries a Sauerbraten server at addr on port and returns the parsed response or an error in case something went wrong. Parsed response means that the int values sent as game mode and master mode are translated into the human readable name, e.g. '12' -> "insta ctf".
-func (s *Server) GetBasicInfo() (BasicInfo, error) {
+func (s *Server) GetBasicInfo(options ...BasicInfoOption) (BasicInfo, error) {
 	basicInfo := BasicInfo{}
 
+	opts := basicInfoOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	basicInfoRaw, err := s.GetBasicInfoRaw()
 	if err != nil {
 		return basicInfo, err
@@ -131,7 +156,9 @@ func (s *Server) GetBasicInfo() (BasicInfo, error) {
 	basicInfo.BasicInfoRaw = basicInfoRaw
 	basicInfo.GameMode = getGameModeName(basicInfo.BasicInfoRaw.GameMode)
 	basicInfo.MasterMode = getMasterModeName(basicInfo.BasicInfoRaw.MasterMode)
-	basicInfo.Map = cubecode.SanitizeString(basicInfo.Map)
-	basicInfo.Description = cubecode.SanitizeString(basicInfo.Description)
+	if !opts.keepColorCodes {
+		basicInfo.Map = cubecode.SanitizeString(basicInfo.Map)
+		basicInfo.Description = cubecode.SanitizeString(basicInfo.Description)
+	}
 	return basicInfo, nil
 }
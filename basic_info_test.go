@@ -0,0 +1,24 @@
+package extinfo
+
+import "testing"
+
+func TestKeepColorCodes(t *testing.T) {
+	basicInfo, err := srv.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basicInfo.Description != "fake server" {
+		t.Errorf("expected color codes to be removed, got %q", basicInfo.Description)
+	}
+
+	basicInfo, err = srv.GetBasicInfo(KeepColorCodes(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basicInfo.Description != "\f3fake \f7server" {
+		t.Errorf("expected color codes to be kept, got %q", basicInfo.Description)
+	}
+	if basicInfo.GameMode != "insta ctf" {
+		t.Errorf("expected parsed game mode, got %q", basicInfo.GameMode)
+	}
+}
@@ -0,0 +1,42 @@
+package extinfo
+
+import (
+	"testing"
+)
+
+func BenchmarkGetBasicInfo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := srv.GetBasicInfo(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetTeamScores(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := srv.GetTeamScores(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetAllClientInfo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := srv.GetAllClientInfo(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetAllClientInfoInto(b *testing.B) {
+	b.ReportAllocs()
+	dst := map[int]ClientInfo{}
+	for i := 0; i < b.N; i++ {
+		if err := srv.GetAllClientInfoInto(dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
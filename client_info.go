@@ -2,28 +2,31 @@ package extinfo
 
 import (
 	"errors"
+	"iter"
 	"net"
+	"strconv"
 
 	"github.com/sauerbraten/cubecode"
 )
 
 // ClientInfoRaw contains the raw information sent back from the server, i.e. state and privilege are ints.
 type ClientInfoRaw struct {
-	ClientNum int    `json:"clientNum"` // client number or cn
-	Ping      int    `json:"ping"`      // client's ping to server
-	Name      string `json:"name"`      //
-	Team      string `json:"team"`      // name of the team the client is on, e.g. "good"
-	Frags     int    `json:"frags"`     // kills
-	Flags     int    `json:"flags"`     // number of flags the player scored
-	Deaths    int    `json:"deaths"`    //
-	Teamkills int    `json:"teamkills"` //
-	Accuracy  int    `json:"accuracy"`  // damage the client could have dealt * 100 / damage actually dealt by the client
-	Health    int    `json:"health"`    // remaining HP (health points)
-	Armour    int    `json:"armour"`    // remaining armour
-	Weapon    int    `json:"weapon"`    // weapon the client currently has selected
-	Privilege int    `json:"privilege"` // 0 ("none"), 1 ("master"), 2 ("auth") or 3 ("admin")
-	State     int    `json:"state"`     // client state, e.g. 1 ("alive") or 5 ("spectator"), see names.go for int -> string mapping
-	IP        net.IP `json:"ip"`        // client IP (only the first 3 bytes)
+	ClientNum int       `json:"clientNum"`           // client number or cn
+	Ping      int       `json:"ping"`                // client's ping to server
+	Name      string    `json:"name"`                //
+	Team      string    `json:"team"`                // name of the team the client is on, e.g. "good"
+	Frags     int       `json:"frags"`               // kills
+	Flags     int       `json:"flags"`               // number of flags the player scored, or skulls in collect modes
+	Deaths    int       `json:"deaths"`              //
+	Teamkills int       `json:"teamkills"`           //
+	Accuracy  int       `json:"accuracy"`            // damage the client could have dealt * 100 / damage actually dealt by the client
+	Health    int       `json:"health"`              // remaining HP (health points)
+	Armour    int       `json:"armour"`              // remaining armour
+	Weapon    int       `json:"weapon"`              // weapon the client currently has selected
+	Privilege int       `json:"privilege"`           // 0 ("none"), 1 ("master"), 2 ("auth") or 3 ("admin")
+	State     int       `json:"state"`               // client state, e.g. 1 ("alive") or 5 ("spectator"), see names.go for int -> string mapping
+	IP        net.IP    `json:"ip"`                  // client IP (only the first 3 bytes)
+	Extension Extension `json:"extension,omitempty"` // mod-specific data following the standard fields, see RegisterExtensionParsers()
 }
 
 // ClientInfo contains the parsed information sent back from the server, i.e. weapon, state and privilege are translated into human readable strings.
@@ -32,17 +35,72 @@ type ClientInfo struct {
 	Weapon    string `json:"weapon"`    // weapon the client currently has selected
 	Privilege string `json:"privilege"` // "none", "master" or "admin"
 	State     string `json:"state"`     // client state, e.g. "dead" or "spectator"
+	IsBot     bool   `json:"isBot"`     // true for bots, which have CNs above MaxPlayerCN
 }
 
-// GetClientInfoRaw returns the raw information about the client with the given clientNum.
+// ClientInfoOption changes which clients GetAllClientInfo() returns.
+type ClientInfoOption func(*clientInfoOptions)
+
+type clientInfoOptions struct {
+	includeBots bool
+}
+
+// IncludeBots sets whether bots are returned along with human clients. By default, they are.
+func IncludeBots(include bool) ClientInfoOption {
+	return func(o *clientInfoOptions) {
+		o.includeBots = include
+	}
+}
+
+// ClientCounts contains the number of clients of each kind.
+type ClientCounts struct {
+	Humans     int `json:"humans"`     // human clients, including spectators
+	Spectators int `json:"spectators"` // human clients spectating
+	Bots       int `json:"bots"`       //
+}
+
+// CountClients counts the humans, spectators and bots in allClientInfo, as returned by GetAllClientInfo().
+func CountClients(allClientInfo map[int]ClientInfo) (counts ClientCounts) {
+	for _, clientInfo := range allClientInfo {
+		switch {
+		case clientInfo.IsBot:
+			counts.Bots++
+		case clientInfo.State == "spectator":
+			counts.Humans++
+			counts.Spectators++
+		default:
+			counts.Humans++
+		}
+	}
+
+	return
+}
+
+// translates the ints in clientInfoRaw into human readable strings
+func newClientInfo(clientInfoRaw ClientInfoRaw) ClientInfo {
+	return ClientInfo{
+		ClientInfoRaw: clientInfoRaw,
+		Weapon:        getWeaponName(clientInfoRaw.Weapon),
+		Privilege:     getPrivilegeName(clientInfoRaw.Privilege),
+		State:         getStateName(clientInfoRaw.State),
+		IsBot:         clientInfoRaw.ClientNum > MaxPlayerCN,
+	}
+}
+
+// GetClientInfoRaw returns the raw information about the client with the given clientNum, or an UnknownClientError if the server has no such client.
 func (s *Server) GetClientInfoRaw(clientNum int) (clientInfoRaw ClientInfoRaw, err error) {
-	response, err := s.queryServer(buildRequest(InfoTypeExtended, ExtInfoTypeClientInfo, clientNum))
+	responses, err := s.queryClientInfo(clientNum)
 	if err != nil {
 		return
 	}
 
-	clientInfoRaw, err = parseClientInfoResponse(response)
-	return
+	if len(responses) != 1 {
+		err = errors.New("extinfo: invalid response: expected information about 1 client, got " + strconv.Itoa(len(responses)))
+		return
+	}
+	response := responses[0]
+
+	return s.parseClientInfo(response, ClientInfoRaw{})
 }
 
 // GetClientInfo returns the parsed information about the client with the given clientNum.
@@ -52,60 +110,140 @@ func (s *Server) GetClientInfo(clientNum int) (clientInfo ClientInfo, err error)
 		return clientInfo, err
 	}
 
-	clientInfo.ClientInfoRaw = clientInfoRaw
-	clientInfo.Weapon = getWeaponName(clientInfo.ClientInfoRaw.Weapon)
-	clientInfo.Privilege = getPrivilegeName(clientInfo.ClientInfoRaw.Privilege)
-	clientInfo.State = getStateName(clientInfo.ClientInfoRaw.State)
-
-	return clientInfo, nil
+	return newClientInfo(clientInfoRaw), nil
 }
 
-// GetAllClientInfo returns the ClientInfo of all Players (including spectators) as a []ClientInfo
-func (s *Server) GetAllClientInfo() (allClientInfo map[int]ClientInfo, err error) {
+// GetAllClientInfo returns the ClientInfo of all clients (including spectators and, unless excluded using IncludeBots(false), bots) mapped to their CN.
+func (s *Server) GetAllClientInfo(options ...ClientInfoOption) (allClientInfo map[int]ClientInfo, err error) {
 	allClientInfo = map[int]ClientInfo{}
+	err = s.GetAllClientInfoInto(allClientInfo, options...)
+	return
+}
 
-	response, err := s.queryServer(buildRequest(InfoTypeExtended, ExtInfoTypeClientInfo, -1))
-	if err != nil {
-		return allClientInfo, err
+// GetAllClientInfoRaw returns the raw information about the same clients GetAllClientInfo() returns, mapped to their CN.
+func (s *Server) GetAllClientInfoRaw(options ...ClientInfoOption) (allClientInfoRaw map[int]ClientInfoRaw, err error) {
+	opts := clientInfoOptions{includeBots: true}
+	for _, option := range options {
+		option(&opts)
 	}
 
-	// response is multiple packets, one for each client
-	// parse each packet on its own and append to allClientInfo
-	clientInfoRaw := ClientInfoRaw{}
-	for response.HasRemaining() {
-		var partialResponse *cubecode.Packet
-		partialResponse, err = response.SubPacket(MaxPacketLength)
-		if err != nil {
-			return
+	allClientInfoRaw = map[int]ClientInfoRaw{}
+	var parseErr error
+	_, err = s.streamClientInfo(-1, func(cn int, response *cubecode.Packet) bool {
+		if !opts.includeBots && cn > MaxPlayerCN {
+			return true
 		}
 
-		clientInfoRaw, err = parseClientInfoResponse(partialResponse)
+		clientInfoRaw, err := s.parseClientInfo(response, ClientInfoRaw{})
 		if err != nil {
-			return
+			parseErr = err
+			return false
 		}
 
-		allClientInfo[clientInfoRaw.ClientNum] = ClientInfo{
-			ClientInfoRaw: clientInfoRaw,
-			Weapon:        getWeaponName(clientInfoRaw.Weapon),
-			Privilege:     getPrivilegeName(clientInfoRaw.Privilege),
-			State:         getStateName(clientInfoRaw.State),
-		}
+		allClientInfoRaw[cn] = clientInfoRaw
+		return true
+	})
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return
 }
 
-// own function, because it is used in GetClientInfo() & GetAllClientInfo()
-func parseClientInfoResponse(response *cubecode.Packet) (clientInfoRaw ClientInfoRaw, err error) {
-	// omit 7 first bytes: EXTENDED_INFO, EXTENDED_INFO_CLIENT_INFO, CN, EXTENDED_INFO_ACK, EXTENDED_INFO_VERSION, EXTENDED_INFO_NO_ERROR, EXTENDED_INFO_CLIENT_INFO_RESPONSE_INFO
-	for i := 0; i < 7; i++ {
-		_, err = response.ReadInt()
+// GetAllClientInfoInto is like GetAllClientInfo(), but stores the clients in dst instead of a new map and removes the ones no longer connected. Name, team and IP of a client already in dst are reused if they didn't change, so polling a server over and over using the same map allocates very little.
+// If an error occurs, dst may contain some clients updated and others not.
+func (s *Server) GetAllClientInfoInto(dst map[int]ClientInfo, options ...ClientInfoOption) error {
+	opts := clientInfoOptions{includeBots: true}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	var parseErr error
+	clientNums, err := s.streamClientInfo(-1, func(cn int, response *cubecode.Packet) bool {
+		if !opts.includeBots && cn > MaxPlayerCN {
+			return true
+		}
+
+		clientInfoRaw, err := s.parseClientInfo(response, dst[cn].ClientInfoRaw)
 		if err != nil {
-			err = errors.New("extinfo: error skipping response header: " + err.Error())
+			parseErr = err
+			return false
+		}
+
+		dst[cn] = newClientInfo(clientInfoRaw)
+		return true
+	})
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for cn := range dst {
+		if (!opts.includeBots && cn > MaxPlayerCN) || indexOf(clientNums, cn) < 0 {
+			delete(dst, cn)
+		}
+	}
+
+	return nil
+}
+
+// StreamAllClientInfo returns an iterator over the same clients GetAllClientInfo() returns, yielding each client as soon as its datagram arrived, in no particular order. Breaking out of the loop stops waiting for the remaining clients.
+// If the query fails or a response can't be parsed, the error is yielded with an empty ClientInfo, and iteration ends.
+func (s *Server) StreamAllClientInfo(options ...ClientInfoOption) iter.Seq2[ClientInfo, error] {
+	opts := clientInfoOptions{includeBots: true}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	return func(yield func(ClientInfo, error) bool) {
+		stopped := false
+		var parseErr error
+
+		_, err := s.streamClientInfo(-1, func(cn int, response *cubecode.Packet) bool {
+			if !opts.includeBots && cn > MaxPlayerCN {
+				return true
+			}
+
+			clientInfoRaw, err := s.parseClientInfo(response, ClientInfoRaw{})
+			if err != nil {
+				parseErr = err
+				return false
+			}
+
+			stopped = !yield(newClientInfo(clientInfoRaw), nil)
+			return !stopped
+		})
+		if stopped {
 			return
 		}
+
+		if err == nil {
+			err = parseErr
+		}
+		if err != nil {
+			yield(ClientInfo{}, err)
+		}
 	}
+}
 
+// parses a client info response including the extension of the server's mod; response has to start at the client's CN
+func (s *Server) parseClientInfo(response *cubecode.Packet, reuse ClientInfoRaw) (clientInfoRaw ClientInfoRaw, err error) {
+	clientInfoRaw, err = parseClientInfoResponse(response, reuse)
+	if err != nil {
+		return
+	}
+
+	clientInfoRaw.Extension, err = s.parseExtension(response, func(p ExtensionParsers) ExtensionParser { return p.ClientInfo })
+	return
+}
+
+// own function, because it is used in GetClientInfo() & GetAllClientInfo(); response has to start at the client's CN. Name, team and IP are taken from reuse if they didn't change, to save allocations.
+func parseClientInfoResponse(response *cubecode.Packet, reuse ClientInfoRaw) (clientInfoRaw ClientInfoRaw, err error) {
 	clientInfoRaw.ClientNum, err = response.ReadInt()
 	if err != nil {
 		err = errors.New("extinfo: error reading client number: " + err.Error())
@@ -118,13 +256,13 @@ func parseClientInfoResponse(response *cubecode.Packet) (clientInfoRaw ClientInf
 		return
 	}
 
-	clientInfoRaw.Name, err = response.ReadString()
+	clientInfoRaw.Name, err = readString(response, reuse.Name)
 	if err != nil {
 		err = errors.New("extinfo: error reading client name: " + err.Error())
 		return
 	}
 
-	clientInfoRaw.Team, err = response.ReadString()
+	clientInfoRaw.Team, err = readString(response, reuse.Team)
 	if err != nil {
 		err = errors.New("extinfo: error reading team: " + err.Error())
 		return
@@ -213,7 +351,11 @@ func parseClientInfoResponse(response *cubecode.Packet) (clientInfoRaw ClientInf
 
 	ipByte4 = 0 // sauer never sends 4th IP byte for privacy reasons
 
-	clientInfoRaw.IP = net.IPv4(ipByte1, ipByte2, ipByte3, ipByte4)
+	if ip := reuse.IP.To4(); ip != nil && ip[0] == ipByte1 && ip[1] == ipByte2 && ip[2] == ipByte3 && ip[3] == ipByte4 {
+		clientInfoRaw.IP = reuse.IP
+	} else {
+		clientInfoRaw.IP = net.IPv4(ipByte1, ipByte2, ipByte3, ipByte4)
+	}
 
 	return
 }
@@ -0,0 +1,135 @@
+package extinfo
+
+import (
+	"net"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestBots(t *testing.T) {
+	allClientInfo, err := srv.GetAllClientInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cn, clientInfo := range allClientInfo {
+		if clientInfo.IsBot != (cn > MaxPlayerCN) {
+			t.Errorf("cn %d: IsBot is %v", cn, clientInfo.IsBot)
+		}
+	}
+
+	counts := CountClients(allClientInfo)
+	if counts != (ClientCounts{Humans: 3, Spectators: 1, Bots: 1}) {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+
+	humans, err := srv.GetAllClientInfo(IncludeBots(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(humans) != 3 {
+		t.Errorf("expected 3 human clients, got %d", len(humans))
+	}
+	for cn := range humans {
+		if cn > MaxPlayerCN {
+			t.Errorf("bot with cn %d was not excluded", cn)
+		}
+	}
+}
+
+func TestStreamAllClientInfo(t *testing.T) {
+	seen := map[int]bool{}
+	for clientInfo, err := range srv.StreamAllClientInfo(IncludeBots(false)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[clientInfo.ClientNum] {
+			t.Errorf("cn %d yielded twice", clientInfo.ClientNum)
+		}
+		seen[clientInfo.ClientNum] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected 3 human clients, got %v", seen)
+	}
+
+	n := 0
+	for _, err := range srv.StreamAllClientInfo() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("expected to stop after 1 client, got %d", n)
+	}
+
+	unreachable, err := NewServer(net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := 0
+	for clientInfo, err := range unreachable.StreamAllClientInfo() {
+		if err == nil || clientInfo.Name != "" {
+			t.Errorf("expected only an error, got %+v, %v", clientInfo, err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("expected exactly one error, got %d", errs)
+	}
+}
+
+func TestGetAllClientInfoInto(t *testing.T) {
+	dst := map[int]ClientInfo{
+		99: {ClientInfoRaw: ClientInfoRaw{ClientNum: 99, Name: "left"}},
+	}
+
+	if err := srv.GetAllClientInfoInto(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := dst[99]; ok {
+		t.Error("client no longer connected was not removed")
+	}
+
+	allClientInfo, err := srv.GetAllClientInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dst) != len(allClientInfo) {
+		t.Fatalf("expected %d clients, got %d", len(allClientInfo), len(dst))
+	}
+
+	prev := map[int]ClientInfo{}
+	for cn, clientInfo := range dst {
+		prev[cn] = clientInfo
+	}
+
+	if err := srv.GetAllClientInfoInto(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for cn, clientInfo := range dst {
+		if clientInfo.Name != allClientInfo[cn].Name || clientInfo.IP.String() != allClientInfo[cn].IP.String() {
+			t.Errorf("cn %d: expected %+v, got %+v", cn, allClientInfo[cn], clientInfo)
+		}
+		if clientInfo.Name != "" && unsafe.StringData(clientInfo.Name) != unsafe.StringData(prev[cn].Name) {
+			t.Errorf("cn %d: name was not reused", cn)
+		}
+		if &clientInfo.IP[0] != &prev[cn].IP[0] {
+			t.Errorf("cn %d: IP was not reused", cn)
+		}
+	}
+
+	if err := srv.GetAllClientInfoInto(dst, IncludeBots(false)); err != nil {
+		t.Fatal(err)
+	}
+	for cn := range dst {
+		if cn > MaxPlayerCN {
+			t.Errorf("bot with cn %d was not removed", cn)
+		}
+	}
+}
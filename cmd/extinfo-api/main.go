@@ -0,0 +1,113 @@
+// Command extinfo-api polls a set of Sauerbraten servers in the background and serves their state as JSON over HTTP, for clients that can't speak UDP, like web browsers.
+//
+// Usage:
+//
+//	extinfo-api [flags] host[:port]...
+//
+// See package github.com/sauerbraten/extinfo/api for the available endpoints.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/api"
+	"github.com/sauerbraten/extinfo/archive"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+	"github.com/sauerbraten/extinfo/poll"
+	"github.com/sauerbraten/extinfo/stats"
+	"github.com/sauerbraten/extinfo/uptime"
+)
+
+func main() {
+	listenAddr := flag.String("listen", ":8080", "address to serve HTTP on")
+	interval := flag.Duration("interval", 5*time.Second, "time between polls of each server")
+	timeout := flag.Duration("timeout", 3*time.Second, "time to wait for a server's response")
+	allowOrigin := flag.String("cors", "*", "value of the Access-Control-Allow-Origin header; empty to disable CORS")
+	archiveDir := flag.String("archive", "", "directory to record every poll result in as newline-delimited JSON; empty to disable recording")
+	archiveMaxSize := flag.Int64("archive-max-size", 100<<20, "start a new archive file after this many bytes")
+	archiveMaxAge := flag.Duration("archive-max-age", 24*time.Hour, "start a new archive file after this long")
+	archiveGzip := flag.Bool("archive-gzip", false, "gzip archive files")
+	statsPath := flag.String("stats", "", "SQLite database to record games and player statistics in; empty to disable")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: extinfo-api [flags] host[:port]...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	servers := []*extinfo.Server{}
+	for _, arg := range flag.Args() {
+		addr, err := hostport.Resolve(arg)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		s, err := extinfo.NewServer(*addr, *timeout)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		servers = append(servers, s)
+	}
+
+	poller := poll.New(*interval, servers...)
+
+	if *archiveDir != "" {
+		w, err := archive.NewWriter(archive.Options{
+			Dir:     *archiveDir,
+			MaxSize: *archiveMaxSize,
+			MaxAge:  *archiveMaxAge,
+			Gzip:    *archiveGzip,
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		updates, _ := poller.Subscribe(poll.Lossless())
+		go func() {
+			if err := w.Follow(context.Background(), updates); err != nil {
+				log.Fatalln("recording poll results:", err)
+			}
+		}()
+	}
+
+	if *statsPath != "" {
+		db, err := stats.Open(*statsPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		updates, _ := poller.Subscribe(poll.Lossless())
+		go func() {
+			if err := db.Follow(context.Background(), updates); err != nil {
+				log.Fatalln("recording statistics:", err)
+			}
+		}()
+	}
+
+	monitor := uptime.New()
+	updates, _ := poller.Subscribe(poll.Lossless())
+	go monitor.Follow(context.Background(), updates)
+
+	go poller.Run(context.Background())
+
+	handler := api.New(poller, api.Options{
+		AllowOrigin: *allowOrigin,
+		MaxAge:      *interval,
+		Uptime:      monitor,
+	})
+
+	log.Println("listening on", *listenAddr)
+	log.Fatal(http.ListenAndServe(*listenAddr, handler))
+}
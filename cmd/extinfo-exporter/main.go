@@ -0,0 +1,116 @@
+// Command extinfo-exporter is a Prometheus exporter querying Sauerbraten game servers on demand, in the style of blackbox_exporter.
+//
+// Every request to /probe?target=host[:port]&module=<module> queries the target server and responds with its metrics, plus probe_success and probe_duration_seconds. The port defaults to 28785. Available modules are:
+//
+//	basic    basic info only (default)
+//	uptime   basic info, uptime and server mod
+//	clients  basic info and per-player metrics
+//	teams    basic info and team scores
+//	all      everything above
+//
+// The exporter's own metrics are served on /metrics.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/collector"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+)
+
+var modules = map[string]collector.Options{
+	"basic":   {},
+	"uptime":  {Uptime: true},
+	"clients": {ClientInfo: true},
+	"teams":   {TeamScores: true},
+	"all":     collector.AllOptions,
+}
+
+func main() {
+	listenAddr := flag.String("listen", ":9698", "address to serve HTTP on")
+	timeout := flag.Duration("timeout", 3*time.Second, "time to wait for each of a server's responses; all queries of a probe end before Prometheus' scrape timeout")
+	verbose := flag.Bool("v", false, "log failed probes")
+	flag.Parse()
+
+	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/probe", &probeHandler{timeout: *timeout, logErrors: *verbose})
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html><head><title>extinfo exporter</title></head><body><h1>extinfo exporter</h1><p><a href="/probe?target=localhost:28785&amp;module=all">Probe localhost:28785</a></p><p><a href="/metrics">Metrics</a></p></body></html>`))
+	})
+
+	log.Println("listening on", *listenAddr)
+	log.Fatal(http.ListenAndServe(*listenAddr, nil))
+}
+
+type probeHandler struct {
+	timeout   time.Duration
+	logErrors bool
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := params.Get("module")
+	if moduleName == "" {
+		moduleName = "basic"
+	}
+	options, ok := modules[moduleName]
+	if !ok {
+		http.Error(w, "unknown module "+strconv.Quote(moduleName), http.StatusBadRequest)
+		return
+	}
+
+	addr, err := hostport.Resolve(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serverOptions := []extinfo.ServerOption{}
+	if deadline, ok := h.deadline(r); ok {
+		serverOptions = append(serverOptions, extinfo.Deadline(deadline))
+	}
+
+	s, err := extinfo.NewServer(*addr, h.timeout, serverOptions...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	probe := collector.NewProbe(s, options)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(probe)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+
+	if probe.Err != nil && h.logErrors {
+		log.Printf("probing %s (module %s) failed: %v", target, moduleName, probe.Err)
+	}
+}
+
+// returns when all queries of a probe have to be done by, leaving some leeway within the scrape timeout Prometheus sends along; a probe makes up to 5 queries, which share it
+func (h *probeHandler) deadline(r *http.Request) (deadline time.Time, ok bool) {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Now().Add(time.Duration(seconds*float64(time.Second)) - 500*time.Millisecond), true
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func TestProbe(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	addr := fake.Addr()
+	h := &probeHandler{timeout: time.Second}
+
+	tests := []struct {
+		query    string
+		status   int
+		expected []string
+		missing  []string
+	}{
+		{"target=" + addr.String(), http.StatusOK, []string{"probe_success 1", "sauerbraten_seconds_left"}, []string{"sauerbraten_team_score", "sauerbraten_player_frags"}},
+		{"target=" + addr.String() + "&module=all", http.StatusOK, []string{"probe_success 1", "sauerbraten_team_score", "sauerbraten_player_frags", "sauerbraten_uptime_seconds"}, nil},
+		{"target=127.0.0.1:0&module=basic", http.StatusOK, []string{"probe_success 0", "probe_duration_seconds"}, []string{"sauerbraten_seconds_left"}},
+		{"target=" + addr.String() + "&module=nope", http.StatusBadRequest, nil, nil},
+		{"module=all", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?"+test.query, nil))
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.query, test.status, rec.Code)
+			continue
+		}
+
+		body := rec.Body.String()
+		for _, s := range test.expected {
+			if !strings.Contains(body, s) {
+				t.Errorf("%s: response does not contain %q", test.query, s)
+			}
+		}
+		for _, s := range test.missing {
+			if strings.Contains(body, s) {
+				t.Errorf("%s: response contains %q", test.query, s)
+			}
+		}
+	}
+}
+
+func TestProbeDeadline(t *testing.T) {
+	state := fakeserver.DefaultState()
+	state.Delay = 300 * time.Millisecond
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	addr := fake.Addr()
+	h := &probeHandler{timeout: time.Second}
+
+	// every response arrives within the time out, but all of them together take longer than the scrape
+	req := httptest.NewRequest(http.MethodGet, "/probe?module=all&target="+addr.String(), nil)
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "1.2")
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %v", elapsed)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "probe_success 0") {
+		t.Errorf("expected probe to fail:\n%s", body)
+	}
+}
+
+func TestProbeModeChange(t *testing.T) {
+	// basic info says insta ctf, but the game changed to a mode without teams before team scores were queried
+	state := fakeserver.DefaultState()
+	state.TeamMode = false
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	addr := fake.Addr()
+	h := &probeHandler{timeout: time.Second}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?module=teams&target="+addr.String(), nil))
+	if body := rec.Body.String(); !strings.Contains(body, "probe_success 1") || strings.Contains(body, "sauerbraten_team_score") {
+		t.Errorf("expected probe without team scores to succeed:\n%s", body)
+	}
+}
@@ -0,0 +1,175 @@
+// Command extinfo-web polls a set of Sauerbraten servers in the background and serves status cards and scoreboards as HTML pages, e.g. to embed in clan websites using an iframe.
+//
+// Usage:
+//
+//	extinfo-web [flags] host[:port]...
+//
+// Pages:
+//
+//	/                 status cards of all servers
+//	/{addr}           status card and scoreboard of the server at addr (host:port)
+//	/{addr}/card      only the status card, for embedding
+//
+// Pages reload themselves every -interval.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+	"github.com/sauerbraten/extinfo/poll"
+	"github.com/sauerbraten/extinfo/render"
+)
+
+// wraps the templates provided by package render in complete documents
+const pageTemplates = `
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta http-equiv="refresh" content="{{.Refresh}}">
+	<title>{{.Title}}</title>
+	{{template "style"}}
+</head>
+<body>
+{{end}}
+
+{{define "foot"}}
+{{template "countdown"}}
+</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "head" .}}
+{{range .Views}}<a href="/{{.Addr}}">{{template "card" .}}</a>
+{{end}}
+{{template "foot"}}{{end}}
+
+{{define "server"}}{{template "head" .}}
+{{with index .Views 0}}{{template "card" .}}{{template "scoreboard" .}}{{end}}
+{{template "foot"}}{{end}}
+
+{{define "card-only"}}{{template "head" .}}
+{{with index .Views 0}}{{template "card" .}}{{end}}
+{{template "foot"}}{{end}}
+`
+
+type page struct {
+	Title   string
+	Refresh int // seconds
+	Views   []*render.View
+}
+
+type handler struct {
+	poller    *poll.Poller
+	templates *template.Template
+	refresh   int
+}
+
+func main() {
+	listenAddr := flag.String("listen", ":8081", "address to serve HTTP on")
+	interval := flag.Duration("interval", 5*time.Second, "time between polls of each server")
+	timeout := flag.Duration("timeout", 3*time.Second, "time to wait for a server's response")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: extinfo-web [flags] host[:port]...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	servers := []*extinfo.Server{}
+	for _, arg := range flag.Args() {
+		addr, err := hostport.Resolve(arg)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		s, err := extinfo.NewServer(*addr, *timeout)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		servers = append(servers, s)
+	}
+
+	poller := poll.New(*interval, servers...)
+	go poller.Run(context.Background())
+
+	refresh := int(interval.Seconds())
+	if refresh < 1 {
+		refresh = 1
+	}
+
+	log.Println("listening on", *listenAddr)
+	log.Fatal(http.ListenAndServe(*listenAddr, newHandler(poller, refresh)))
+}
+
+// returns the handler serving the pages, which reload every refresh seconds
+func newHandler(poller *poll.Poller, refresh int) http.Handler {
+	h := &handler{
+		poller:    poller,
+		templates: template.Must(render.Templates().Parse(pageTemplates)),
+		refresh:   refresh,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.index)
+	mux.HandleFunc("GET /{addr}", h.server("server"))
+	mux.HandleFunc("GET /{addr}/card", h.server("card-only"))
+	return mux
+}
+
+func (h *handler) index(w http.ResponseWriter, r *http.Request) {
+	p := page{Title: "Servers", Refresh: h.refresh}
+	for _, state := range h.poller.States() {
+		if state.Snapshot != nil {
+			p.Views = append(p.Views, render.NewView(state.Snapshot))
+		}
+	}
+
+	h.execute(w, "index", p)
+}
+
+// returns a handler executing the named template with the view of the server given in the URL
+func (h *handler) server(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := r.PathValue("addr")
+		state, ok := h.poller.State(addr)
+		if !ok {
+			http.Error(w, "unknown server "+strconv.Quote(addr), http.StatusNotFound)
+			return
+		}
+		if state.Snapshot == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(h.refresh))
+			http.Error(w, "server was not polled successfully yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		view := render.NewView(state.Snapshot)
+		h.execute(w, name, page{
+			Title:   view.Map + " – " + state.Snapshot.Description(),
+			Refresh: h.refresh,
+			Views:   []*render.View{view},
+		})
+	}
+}
+
+func (h *handler) execute(w http.ResponseWriter, name string, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, name, p); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+func TestPages(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fake.Addr()
+
+	p := poll.New(time.Minute, s)
+	p.PollAll()
+	h := newHandler(p, 5)
+
+	tests := []struct {
+		path     string
+		status   int
+		contains []string
+		lacks    []string
+	}{
+		{"/", http.StatusOK, []string{`<meta http-equiv="refresh" content="5">`, `<a href="/` + addr.String() + `">`, `class="sauer-card"`}, []string{`class="sauer-scoreboard"`}},
+		{"/" + addr.String(), http.StatusOK, []string{`class="sauer-card"`, `class="sauer-scoreboard"`, `<title>forge – fake server</title>`}, nil},
+		{"/" + addr.String() + "/card", http.StatusOK, []string{`class="sauer-card"`}, []string{`class="sauer-scoreboard"`}},
+		{"/127.0.0.1:1", http.StatusNotFound, nil, nil},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, w.Code)
+		}
+		body := w.Body.String()
+		for _, s := range test.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("%s: expected %s in:\n%s", test.path, s, body)
+			}
+		}
+		for _, s := range test.lacks {
+			if strings.Contains(body, s) {
+				t.Errorf("%s: unexpected %s", test.path, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/filter"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+	"github.com/sauerbraten/extinfo/internal/master"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// runList queries hostPorts and the servers registered with masterAddr (if not empty) and prints those matching filterExpr, sorted by orderExpr
+func runList(hostPorts []string, masterAddr, filterExpr, orderExpr string, timeout time.Duration, out *printer, stderr io.Writer) int {
+	f, err := filter.Parse(filterExpr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	var order *filter.Order
+	if orderExpr != "" {
+		order, err = filter.ParseOrder(orderExpr)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+
+	if masterAddr != "" {
+		addrs, err := master.List(context.Background(), masterAddr, timeout)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		hostPorts = append(hostPorts, addrs...)
+	}
+
+	if len(hostPorts) == 0 {
+		fmt.Fprintln(stderr, "no servers to list")
+		return exitUsage
+	}
+
+	servers := []*extinfo.Server{}
+	seen := map[string]bool{}
+	for _, hostPort := range hostPorts {
+		addr, err := hostport.Resolve(hostPort)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		if seen[addr.String()] {
+			continue
+		}
+		seen[addr.String()] = true
+
+		s, err := extinfo.NewServer(*addr, timeout)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		servers = append(servers, s)
+	}
+
+	// poll every server once, concurrently
+	poller := poll.New(time.Minute, servers...)
+	poller.PollAll()
+
+	if err := out.list(filter.Select(poller.States(), f, order)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// list prints one line per server
+func (p *printer) list(states []poll.State) error {
+	if p.asJSON {
+		return p.printJSON(states)
+	}
+
+	t := p.table()
+	fmt.Fprintln(t, "SERVER\tPLAYERS\tMODE\tMAP\tMASTER MODE\tTIME LEFT\tPING\tDESCRIPTION")
+	for _, state := range states {
+		if state.Snapshot == nil || state.LastError != "" {
+			fmt.Fprintf(t, "%s\t-\t-\t-\t-\t-\t-\t%s\n", state.Addr, state.LastError)
+			continue
+		}
+
+		snap := state.Snapshot
+		info := snap.BasicInfo
+		gameMode, masterMode := info.GameMode, info.MasterMode
+		if p.raw {
+			gameMode, masterMode = fmt.Sprint(info.BasicInfoRaw.GameMode), fmt.Sprint(info.BasicInfoRaw.MasterMode)
+		}
+		fmt.Fprintf(t, "%s\t%d/%d\t%s\t%s\t%s\t%s\t%dms\t%s\n",
+			state.Addr, info.NumberOfClients, info.MaxNumberOfClients, gameMode, info.Map, masterMode, formatSeconds(info.SecsLeft), snap.Ping, snap.Description())
+	}
+	return t.Flush()
+}
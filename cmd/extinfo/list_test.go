@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+func TestList(t *testing.T) {
+	forge := startFake(t, fakeserver.DefaultState())
+
+	otState := fakeserver.DefaultState()
+	otState.Map = "ot"
+	otState.MasterMode = 2 // locked
+	fake, err := fakeserver.Start(otState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	ot := fake.Addr()
+
+	m, err := fakeserver.StartMaster(ot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	tests := []struct {
+		args     []string
+		exitCode int
+		contains []string
+		lacks    []string
+	}{
+		{[]string{"-master", m.Addr(), "list", forge}, exitOK, []string{"SERVER", forge, ot.String(), "insta ctf"}, nil},
+		{[]string{"-master", m.Addr(), "-filter", "map~^ot mastermode:locked", "list", forge}, exitOK, []string{ot.String()}, []string{forge}},
+		{[]string{"-filter", "!online", "list", forge}, exitOK, []string{"SERVER"}, []string{forge}},
+		{[]string{"-filter", "crowded", "list", forge}, exitUsage, nil, nil},
+		{[]string{"-sort", "size", "list", forge}, exitUsage, nil, nil},
+		{[]string{"list"}, exitUsage, nil, nil},
+	}
+
+	for _, test := range tests {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if exitCode := run(test.args, stdout, stderr); exitCode != test.exitCode {
+			t.Errorf("%v: expected exit code %d, got %d (stderr: %s)", test.args, test.exitCode, exitCode, stderr)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(stdout.String(), s) {
+				t.Errorf("%v: output does not contain %q:\n%s", test.args, s, stdout)
+			}
+		}
+		for _, s := range test.lacks {
+			if strings.Contains(stdout.String(), s) {
+				t.Errorf("%v: output unexpectedly contains %q:\n%s", test.args, s, stdout)
+			}
+		}
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if exitCode := run([]string{"-json", "-master", m.Addr(), "-sort", "-map", "list", forge}, stdout, stderr); exitCode != exitOK {
+		t.Fatalf("exit code %d: %s", exitCode, stderr)
+	}
+	states := []poll.State{}
+	if err := json.Unmarshal(stdout.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].Addr != ot.String() || states[0].Snapshot == nil || states[0].Snapshot.BasicInfo.Map != "ot" {
+		t.Errorf("expected ot to be listed first, got %+v", states)
+	}
+}
@@ -0,0 +1,186 @@
+// Command extinfo queries a Sauerbraten game server and prints the information it sends back.
+//
+// Usage:
+//
+//	extinfo [flags] host[:port] <command>
+//	extinfo [flags] list [host[:port]...]
+//
+// Commands are basic, clients, client <cn>, teams, uptime, mod, all and watch. watch shows a live scoreboard like the in-game one, refreshed every -interval, until interrupted. By default, the output is formatted as a table; use -json for JSON, and -raw to print game mode, weapon, privilege, etc. as the numbers the server sends instead of their names.
+//
+// list queries all given servers, plus those registered with the master server given using -master, and prints one line per server, e.g. to find a game:
+//
+//	extinfo -master master.sauerbraten.org -filter 'mode:"insta ctf" players>=4 !full' -sort -players list
+//
+// See package github.com/sauerbraten/extinfo/filter for the syntax of -filter and -sort.
+//
+// -record file appends every request and the server's response to file, -replay file answers requests from such a recording instead of querying the server, e.g. to reproduce a problem with a server's responses.
+//
+// The exit code is 0 on success, 1 for errors not listed here, 2 for invalid usage, 3 if the server did not respond in time, 4 if the response could not be parsed, 5 if team scores were requested but the server is not running a team mode, and 6 if the server has no client with the requested CN.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+)
+
+// exit codes
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitTimeout
+	exitProtocolError
+	exitNotTeamMode
+	exitUnknownClient
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("extinfo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print JSON instead of tables")
+	raw := flags.Bool("raw", false, "print numbers as sent by the server instead of names (e.g. game mode 12 instead of \"insta ctf\")")
+	timeout := flags.Duration("timeout", 3*time.Second, "time to wait for the server's response")
+	interval := flags.Duration("interval", time.Second, "time between updates in watch mode")
+	record := flags.String("record", "", "append requests and responses to this `file`")
+	replay := flags.String("replay", "", "answer requests using the responses recorded in this `file` instead of querying the server")
+	masterAddr := flags.String("master", "", "list: also query the servers registered with this master `server`")
+	filterExpr := flags.String("filter", "", "list: only print servers matching this `expression`")
+	orderExpr := flags.String("sort", "", "list: sort servers by these comma-separated `fields`, descending if prefixed with -")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: extinfo [flags] host[:port] basic|clients|client <cn>|teams|uptime|mod|all|watch")
+		fmt.Fprintln(stderr, "       extinfo [flags] list [host[:port]...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.Arg(0) == "list" {
+		return runList(flags.Args()[1:], *masterAddr, *filterExpr, *orderExpr, *timeout, newPrinter(stdout, *asJSON, *raw), stderr)
+	}
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	addr, err := hostport.Resolve(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	transport := extinfo.DefaultTransport
+	if *replay != "" {
+		transport, err = extinfo.OpenReplay(*replay)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if *record != "" {
+		f, err := os.OpenFile(*record, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		defer f.Close()
+
+		recorder := extinfo.NewRecorder(f, transport)
+		defer func() {
+			if err := recorder.Err(); err != nil {
+				fmt.Fprintln(stderr, "error recording:", err)
+			}
+		}()
+		transport = recorder
+	}
+
+	s, err := extinfo.NewServer(*addr, *timeout, extinfo.UseTransport(transport))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	out := newPrinter(stdout, *asJSON, *raw)
+	command, commandArgs := flags.Arg(1), flags.Args()[2:]
+
+	switch command {
+	case "basic":
+		err = out.basicInfo(s)
+	case "clients":
+		err = out.allClientInfo(s)
+	case "client":
+		if len(commandArgs) != 1 {
+			flags.Usage()
+			return exitUsage
+		}
+		cn, convErr := strconv.Atoi(commandArgs[0])
+		if convErr != nil {
+			fmt.Fprintln(stderr, "invalid cn:", commandArgs[0])
+			return exitUsage
+		}
+		err = out.clientInfo(s, cn)
+	case "teams":
+		err = out.teamScores(s)
+	case "uptime":
+		err = out.uptime(s)
+	case "mod":
+		err = out.serverMod(s)
+	case "all":
+		err = out.all(s)
+	case "watch":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = out.watch(ctx, s, *interval)
+	default:
+		fmt.Fprintln(stderr, "unknown command:", command)
+		flags.Usage()
+		return exitUsage
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+
+	return exitOK
+}
+
+// maps err to the exit code describing it
+func exitCode(err error) int {
+	var netErr net.Error
+	var opErr *net.OpError
+	var unknownClientErr extinfo.UnknownClientError
+
+	switch {
+	case errors.Is(err, extinfo.ErrNotTeamMode):
+		return exitNotTeamMode
+	case errors.As(err, &unknownClientErr):
+		return exitUnknownClient
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return exitTimeout
+	case errors.As(err, &opErr), errors.Is(err, extinfo.ErrNotRecorded):
+		// e.g. connection refused, or a request missing from the recording given using -replay
+		return exitError
+	default:
+		// everything else is the response not being what we expected
+		return exitProtocolError
+	}
+}
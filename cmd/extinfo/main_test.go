@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/cubecode"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func startFake(t *testing.T, state fakeserver.State) string {
+	t.Helper()
+
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fake.Close() })
+
+	addr := fake.Addr()
+	return addr.String()
+}
+
+// starts a UDP server on the info port of the returned address which passes every request to respond and sends back what it returns, if anything
+func startBroken(t *testing.T, respond func(request []byte) []byte) string {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if response := respond(buf[:n]); response != nil {
+				conn.WriteToUDP(response, addr)
+			}
+		}
+	}()
+
+	addr := *conn.LocalAddr().(*net.UDPAddr)
+	addr.Port--
+	return addr.String()
+}
+
+func TestRun(t *testing.T) {
+	server := startFake(t, fakeserver.DefaultState())
+
+	notTeamModeState := fakeserver.DefaultState()
+	notTeamModeState.TeamMode = false
+	notTeamMode := startFake(t, notTeamModeState)
+
+	silent := startBroken(t, func([]byte) []byte { return nil })
+	garbage := startBroken(t, func(request []byte) []byte { return append(request, 0x12, 0x34, 0x56) })
+
+	emptyRecording := filepath.Join(t.TempDir(), "empty.ndjson")
+	if err := os.WriteFile(emptyRecording, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args     []string
+		exitCode int
+		output   string
+	}{
+		{[]string{server, "basic"}, exitOK, "insta ctf"},
+		{[]string{"-raw", server, "basic"}, exitOK, "Game Mode:         12"},
+		{[]string{server, "clients"}, exitOK, "carol"},
+		{[]string{server, "client", "128"}, exitOK, "Bot:            true"},
+		{[]string{"-raw", server, "client", "10"}, exitOK, "Privilege:      1"},
+		{[]string{"-raw", server, "clients"}, exitOK, "4       80    1     1      10.0.1.0"},
+		{[]string{server, "teams"}, exitOK, "good"},
+		{[]string{server, "uptime"}, exitOK, "1h0m0s"},
+		{[]string{server, "mod"}, exitOK, "vanilla"},
+		{[]string{server, "all"}, exitOK, "== Clients =="},
+		{[]string{notTeamMode, "all"}, exitOK, "not a team mode"},
+		{[]string{notTeamMode, "teams"}, exitNotTeamMode, ""},
+		{[]string{"-timeout", "50ms", silent, "uptime"}, exitTimeout, ""},
+		{[]string{garbage, "uptime"}, exitProtocolError, ""},
+		{[]string{server, "client", "7"}, exitUnknownClient, ""},
+		{[]string{"-replay", emptyRecording, server, "uptime"}, exitError, ""},
+		{[]string{server}, exitUsage, ""},
+		{[]string{server, "client"}, exitUsage, ""},
+		{[]string{server, "frobnicate"}, exitUsage, ""},
+	}
+
+	for _, test := range tests {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		exitCode := run(test.args, stdout, stderr)
+		if exitCode != test.exitCode {
+			t.Errorf("%v: expected exit code %d, got %d (stderr: %s)", test.args, test.exitCode, exitCode, stderr)
+		}
+		if !strings.Contains(stdout.String(), test.output) {
+			t.Errorf("%v: output does not contain %q:\n%s", test.args, test.output, stdout)
+		}
+	}
+}
+
+func TestRunWrappedTimeout(t *testing.T) {
+	// extensions are only parsed with parsers registered, which makes basic info with bytes left detect the server's mod
+	extinfo.RegisterExtensionParsers("hopmod", extinfo.ExtensionParsers{BasicInfo: func(*cubecode.Packet) (extinfo.Extension, error) { return nil, nil }})
+	defer extinfo.RegisterExtensionParsers("hopmod", extinfo.ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.BasicInfoExtension = []byte{1}
+	fake, err := net.ResolveUDPAddr("udp", startFake(t, state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Port++
+
+	// answers basic info requests, but not the one detecting the mod
+	server := startBroken(t, func(request []byte) []byte {
+		if bytes.Equal(request, []byte{0, 0, 1}) {
+			return nil
+		}
+		conn, err := net.DialUDP("udp", nil, fake)
+		if err != nil {
+			return nil
+		}
+		defer conn.Close()
+		conn.Write(request)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 512)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil
+		}
+		return buf[:n]
+	})
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if exitCode := run([]string{"-timeout", "50ms", server, "basic"}, stdout, stderr); exitCode != exitTimeout {
+		t.Errorf("expected exit code %d, got %d (stderr: %s)", exitTimeout, exitCode, stderr)
+	}
+	if !strings.Contains(stderr.String(), "error detecting server mod") {
+		t.Errorf("unexpected error %s", stderr)
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	server := startFake(t, fakeserver.DefaultState())
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if exitCode := run([]string{"-json", server, "clients"}, stdout, stderr); exitCode != exitOK {
+		t.Fatalf("exit code %d: %s", exitCode, stderr)
+	}
+
+	clients := []extinfo.ClientInfo{}
+	if err := json.Unmarshal(stdout.Bytes(), &clients); err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 4 || clients[0].ClientNum != 0 || clients[3].ClientNum != 128 {
+		t.Errorf("expected 4 clients sorted by CN, got %+v", clients)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fake.Addr()
+	server := addr.String()
+
+	recording := filepath.Join(t.TempDir(), "recording.ndjson")
+
+	recorded, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if exitCode := run([]string{"-record", recording, server, "all"}, recorded, stderr); exitCode != exitOK {
+		t.Fatalf("exit code %d: %s", exitCode, stderr)
+	}
+
+	fake.Close()
+
+	replayed := &bytes.Buffer{}
+	if exitCode := run([]string{"-replay", recording, server, "all"}, replayed, stderr); exitCode != exitOK {
+		t.Fatalf("exit code %d: %s", exitCode, stderr)
+	}
+
+	if replayed.String() != recorded.String() {
+		t.Errorf("expected replay to print\n%s\ngot\n%s", recorded, replayed)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+)
+
+// printer queries a server and writes the results as tables or JSON.
+type printer struct {
+	w      io.Writer
+	asJSON bool
+	raw    bool
+}
+
+func newPrinter(w io.Writer, asJSON, raw bool) *printer {
+	return &printer{
+		w:      w,
+		asJSON: asJSON,
+		raw:    raw,
+	}
+}
+
+func (p *printer) printJSON(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+func (p *printer) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+}
+
+func (p *printer) basicInfo(s *extinfo.Server) error {
+	if p.raw {
+		basicInfo, err := s.GetBasicInfoRaw()
+		if err != nil {
+			return err
+		}
+		if p.asJSON {
+			return p.printJSON(basicInfo)
+		}
+		return p.printBasicInfo(basicInfo, fmt.Sprint(basicInfo.GameMode), fmt.Sprint(basicInfo.MasterMode))
+	}
+
+	basicInfo, err := s.GetBasicInfo()
+	if err != nil {
+		return err
+	}
+	if p.asJSON {
+		return p.printJSON(basicInfo)
+	}
+	return p.printBasicInfo(basicInfo.BasicInfoRaw, basicInfo.GameMode, basicInfo.MasterMode)
+}
+
+func (p *printer) printBasicInfo(basicInfo extinfo.BasicInfoRaw, gameMode, masterMode string) error {
+	t := p.table()
+	fmt.Fprintf(t, "Description:\t%s\n", basicInfo.Description)
+	fmt.Fprintf(t, "Map:\t%s\n", basicInfo.Map)
+	fmt.Fprintf(t, "Game Mode:\t%s\n", gameMode)
+	fmt.Fprintf(t, "Master Mode:\t%s\n", masterMode)
+	fmt.Fprintf(t, "Clients:\t%d/%d\n", basicInfo.NumberOfClients, basicInfo.MaxNumberOfClients)
+	fmt.Fprintf(t, "Time Left:\t%s\n", formatSeconds(basicInfo.SecsLeft))
+	fmt.Fprintf(t, "Paused:\t%t\n", basicInfo.Paused)
+	fmt.Fprintf(t, "Game Speed:\t%d\n", basicInfo.GameSpeed)
+	fmt.Fprintf(t, "Protocol Version:\t%d\n", basicInfo.ProtocolVersion)
+	return t.Flush()
+}
+
+func (p *printer) allClientInfo(s *extinfo.Server) error {
+	if p.raw {
+		allClientInfoRaw, err := s.GetAllClientInfoRaw()
+		if err != nil {
+			return err
+		}
+		clients := sortedByCN(allClientInfoRaw)
+		if p.asJSON {
+			return p.printJSON(clients)
+		}
+
+		t := p.clientsTable()
+		for _, c := range clients {
+			printClientRow(t, c, fmt.Sprint(c.Weapon), fmt.Sprint(c.Privilege), fmt.Sprint(c.State))
+		}
+		return t.Flush()
+	}
+
+	allClientInfo, err := s.GetAllClientInfo()
+	if err != nil {
+		return err
+	}
+	clients := sortedByCN(allClientInfo)
+	if p.asJSON {
+		return p.printJSON(clients)
+	}
+
+	t := p.clientsTable()
+	for _, c := range clients {
+		printClientRow(t, c.ClientInfoRaw, c.Weapon, c.Privilege, c.State)
+	}
+	return t.Flush()
+}
+
+func (p *printer) clientsTable() *tabwriter.Writer {
+	t := p.table()
+	fmt.Fprintln(t, "CN\tNAME\tTEAM\tFRAGS\tDEATHS\tFLAGS\tTKS\tACC\tHP\tARMOUR\tWEAPON\tPING\tPRIV\tSTATE\tIP")
+	return t
+}
+
+func printClientRow(t *tabwriter.Writer, c extinfo.ClientInfoRaw, weapon, privilege, state string) {
+	fmt.Fprintf(t, "%d\t%s\t%s\t%d\t%d\t%d\t%d\t%d%%\t%d\t%d\t%s\t%d\t%s\t%s\t%s\n",
+		c.ClientNum, c.Name, c.Team, c.Frags, c.Deaths, c.Flags, c.Teamkills, c.Accuracy, c.Health, c.Armour, weapon, c.Ping, privilege, state, c.IP)
+}
+
+func (p *printer) clientInfo(s *extinfo.Server, cn int) error {
+	if p.raw {
+		clientInfo, err := s.GetClientInfoRaw(cn)
+		if err != nil {
+			return err
+		}
+		if p.asJSON {
+			return p.printJSON(clientInfo)
+		}
+		return p.printClientInfo(clientInfo, clientInfo.ClientNum > extinfo.MaxPlayerCN, fmt.Sprint(clientInfo.Weapon), fmt.Sprint(clientInfo.Privilege), fmt.Sprint(clientInfo.State))
+	}
+
+	clientInfo, err := s.GetClientInfo(cn)
+	if err != nil {
+		return err
+	}
+	if p.asJSON {
+		return p.printJSON(clientInfo)
+	}
+	return p.printClientInfo(clientInfo.ClientInfoRaw, clientInfo.IsBot, clientInfo.Weapon, clientInfo.Privilege, clientInfo.State)
+}
+
+func (p *printer) printClientInfo(clientInfo extinfo.ClientInfoRaw, isBot bool, weapon, privilege, state string) error {
+	t := p.table()
+	fmt.Fprintf(t, "Name:\t%s\n", clientInfo.Name)
+	fmt.Fprintf(t, "Client Number:\t%d\n", clientInfo.ClientNum)
+	fmt.Fprintf(t, "Bot:\t%t\n", isBot)
+	fmt.Fprintf(t, "Team:\t%s\n", clientInfo.Team)
+	fmt.Fprintf(t, "Frags:\t%d\n", clientInfo.Frags)
+	fmt.Fprintf(t, "Deaths:\t%d\n", clientInfo.Deaths)
+	fmt.Fprintf(t, "Flags:\t%d\n", clientInfo.Flags)
+	fmt.Fprintf(t, "Teamkills:\t%d\n", clientInfo.Teamkills)
+	fmt.Fprintf(t, "Accuracy:\t%d%%\n", clientInfo.Accuracy)
+	fmt.Fprintf(t, "Health:\t%d\n", clientInfo.Health)
+	fmt.Fprintf(t, "Armour:\t%d\n", clientInfo.Armour)
+	fmt.Fprintf(t, "Weapon:\t%s\n", weapon)
+	fmt.Fprintf(t, "Ping:\t%d\n", clientInfo.Ping)
+	fmt.Fprintf(t, "Privilege:\t%s\n", privilege)
+	fmt.Fprintf(t, "State:\t%s\n", state)
+	fmt.Fprintf(t, "IP:\t%s\n", clientInfo.IP)
+	return t.Flush()
+}
+
+func (p *printer) teamScores(s *extinfo.Server) error {
+	teamScores, err := s.GetTeamScores()
+	if err != nil {
+		return err
+	}
+
+	if p.asJSON {
+		if p.raw {
+			return p.printJSON(teamScores.TeamScoresRaw)
+		}
+		return p.printJSON(teamScores)
+	}
+
+	gameMode := teamScores.GameMode
+	if p.raw {
+		gameMode = fmt.Sprint(teamScores.TeamScoresRaw.GameMode)
+	}
+
+	names := make([]string, 0, len(teamScores.Scores))
+	for name := range teamScores.Scores {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := teamScores.Scores[names[i]], teamScores.Scores[names[j]]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Name < b.Name
+	})
+
+	fmt.Fprintf(p.w, "Game Mode: %s, Time Left: %s\n", gameMode, formatSeconds(teamScores.SecsLeft))
+
+	t := p.table()
+	fmt.Fprintln(t, "TEAM\tSCORE\tBASES")
+	for _, name := range names {
+		score := teamScores.Scores[name]
+		bases := make([]string, 0, len(score.Bases))
+		for _, base := range score.Bases {
+			bases = append(bases, fmt.Sprint(base))
+		}
+		fmt.Fprintf(t, "%s\t%d\t%s\n", score.Name, score.Score, strings.Join(bases, ","))
+	}
+	return t.Flush()
+}
+
+func (p *printer) uptime(s *extinfo.Server) error {
+	uptime, err := s.GetUptime()
+	if err != nil {
+		return err
+	}
+
+	if p.asJSON {
+		return p.printJSON(map[string]int{"uptime": uptime})
+	}
+
+	if p.raw {
+		_, err = fmt.Fprintln(p.w, uptime)
+		return err
+	}
+
+	_, err = fmt.Fprintln(p.w, time.Duration(uptime)*time.Second)
+	return err
+}
+
+func (p *printer) serverMod(s *extinfo.Server) error {
+	mod, err := s.GetServerMod()
+	if err != nil {
+		return err
+	}
+
+	if p.asJSON {
+		return p.printJSON(map[string]string{"mod": mod})
+	}
+
+	if mod == "" {
+		mod = "none detected (probably vanilla)"
+	}
+	_, err = fmt.Fprintln(p.w, mod)
+	return err
+}
+
+// all prints everything; team scores are skipped when no team mode is being played
+func (p *printer) all(s *extinfo.Server) error {
+	if p.asJSON {
+		return p.allJSON(s)
+	}
+
+	sections := []struct {
+		title string
+		print func(*extinfo.Server) error
+	}{
+		{"Basic Info", p.basicInfo},
+		{"Server Mod", p.serverMod},
+		{"Uptime", p.uptime},
+		{"Teams", p.teamScores},
+		{"Clients", p.allClientInfo},
+	}
+
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(p.w)
+		}
+		fmt.Fprintf(p.w, "== %s ==\n", section.title)
+
+		err := section.print(s)
+		if errors.Is(err, extinfo.ErrNotTeamMode) {
+			fmt.Fprintln(p.w, "not a team mode")
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *printer) allJSON(s *extinfo.Server) error {
+	result := map[string]interface{}{}
+
+	var err error
+	if p.raw {
+		result["basicInfo"], err = s.GetBasicInfoRaw()
+	} else {
+		result["basicInfo"], err = s.GetBasicInfo()
+	}
+	if err != nil {
+		return err
+	}
+
+	if result["mod"], err = s.GetServerMod(); err != nil {
+		return err
+	}
+
+	if result["uptime"], err = s.GetUptime(); err != nil {
+		return err
+	}
+
+	var teamScores interface{}
+	if p.raw {
+		teamScores, err = s.GetTeamScoresRaw()
+	} else {
+		teamScores, err = s.GetTeamScores()
+	}
+	if err == nil {
+		result["teamScores"] = teamScores
+	} else if !errors.Is(err, extinfo.ErrNotTeamMode) {
+		return err
+	}
+
+	// a list sorted by CN is easier to work with than an object keyed by CN
+	if p.raw {
+		var allClientInfoRaw map[int]extinfo.ClientInfoRaw
+		allClientInfoRaw, err = s.GetAllClientInfoRaw()
+		result["clients"] = sortedByCN(allClientInfoRaw)
+	} else {
+		var allClientInfo map[int]extinfo.ClientInfo
+		allClientInfo, err = s.GetAllClientInfo()
+		result["clients"] = sortedByCN(allClientInfo)
+	}
+	if err != nil {
+		return err
+	}
+
+	return p.printJSON(result)
+}
+
+// returns the clients in allClientInfo, as returned by GetAllClientInfo() or GetAllClientInfoRaw(), sorted by CN
+func sortedByCN[T any](allClientInfo map[int]T) []T {
+	clients := make([]T, 0, len(allClientInfo))
+	for _, cn := range slices.Sorted(maps.Keys(allClientInfo)) {
+		clients = append(clients, allClientInfo[cn])
+	}
+	return clients
+}
+
+// formats seconds as m:ss
+func formatSeconds(seconds int) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
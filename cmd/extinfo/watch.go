@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/colors"
+)
+
+// ANSI escape sequences
+const (
+	enterAltScreen = "\x1b[?1049h"
+	leaveAltScreen = "\x1b[?1049l"
+	hideCursor     = "\x1b[?25l"
+	showCursor     = "\x1b[?25h"
+	clearScreen    = "\x1b[H\x1b[2J"
+
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reverse = "\x1b[7m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	magenta = "\x1b[35m"
+)
+
+// the state of the server at one point in time
+type snapshot struct {
+	basicInfo  extinfo.BasicInfo
+	teamScores *extinfo.TeamScores // nil outside of team modes
+	clients    map[int]extinfo.ClientInfo
+}
+
+func querySnapshot(s *extinfo.Server) (*snapshot, error) {
+	basicInfo, err := s.GetBasicInfo(extinfo.KeepColorCodes(true))
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &snapshot{basicInfo: basicInfo}
+
+	if extinfo.IsTeamMode(basicInfo.GameMode) {
+		teamScores, err := s.GetTeamScores()
+		// the game mode might have changed in the meantime
+		if err != nil && !errors.Is(err, extinfo.ErrNotTeamMode) {
+			return nil, err
+		}
+		if err == nil {
+			snap.teamScores = &teamScores
+		}
+	}
+
+	snap.clients, err = s.GetAllClientInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// watch polls s every interval and redraws the scoreboard in place, until ctx is cancelled
+func (p *printer) watch(ctx context.Context, s *extinfo.Server, interval time.Duration) error {
+	fmt.Fprint(p.w, enterAltScreen+hideCursor)
+	defer fmt.Fprint(p.w, showCursor+leaveAltScreen)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *snapshot
+	for {
+		cur, err := querySnapshot(s)
+		if err != nil {
+			// keep showing the last state, servers drop packets every now and then
+			fmt.Fprint(p.w, clearScreen+renderScoreboard(prev, prev)+red+"error: "+err.Error()+reset+"\n")
+		} else {
+			fmt.Fprint(p.w, clearScreen+renderScoreboard(cur, prev))
+			prev = cur
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// widths of the scoreboard columns
+const (
+	nameWidth   = 16
+	numberWidth = 6
+)
+
+// renders cur like the in-game scoreboard, highlighting what changed since prev (which may be nil)
+func renderScoreboard(cur, prev *snapshot) string {
+	if cur == nil {
+		return "waiting for server…\n"
+	}
+
+	b := &strings.Builder{}
+	info := cur.basicInfo
+
+	timeLeft := formatSeconds(info.SecsLeft) + " left"
+	if info.SecsLeft <= 0 {
+		timeLeft = "intermission"
+	}
+	if info.Paused {
+		timeLeft += " (paused)"
+	}
+
+	fmt.Fprintf(b, "%s%s%s\n", bold, colors.ANSI(info.Description), reset)
+	fmt.Fprintf(b, "%s | %s | %s | %s | %d/%d clients\n\n", colors.Strip(info.Map), info.GameMode, timeLeft, info.MasterMode, info.NumberOfClients, info.MaxNumberOfClients)
+
+	sb := extinfo.NewScoreboard(info, cur.teamScores, cur.clients)
+	flagMode := sb.FlagMode
+
+	var prevClients map[int]extinfo.ClientInfo
+	if prev != nil {
+		prevClients = prev.clients
+	}
+
+	columns := [][]string{}
+	for _, team := range sb.Teams {
+		if !sb.TeamMode {
+			columns = append(columns, renderPlayers(team.Players, prevClients, flagMode))
+			continue
+		}
+
+		header := fmt.Sprintf("%s: %d", team.Name, team.Score)
+		header = pad(header, columnWidth(flagMode))
+		if prev != nil && (prev.teamScores == nil || prev.teamScores.Scores[team.Name].Score != team.Score) {
+			header = reverse + header + reset
+		}
+
+		column := append([]string{bold + header + reset}, renderPlayers(team.Players, prevClients, flagMode)...)
+		columns = append(columns, column)
+	}
+
+	b.WriteString(sideBySide(columns, columnWidth(flagMode)))
+
+	if len(sb.Spectators) > 0 {
+		spectators := make([]string, 0, len(sb.Spectators))
+		for _, spectator := range sb.Spectators {
+			spectators = append(spectators, colors.Strip(spectator.Name))
+		}
+		fmt.Fprintf(b, "\n%sspectators:%s %s\n", dim, reset, strings.Join(spectators, ", "))
+	}
+
+	return b.String()
+}
+
+func columnWidth(flagMode bool) int {
+	if flagMode {
+		return nameWidth + 4*numberWidth
+	}
+	return nameWidth + 3*numberWidth
+}
+
+// renders a header line and one line per player
+func renderPlayers(players []extinfo.ScoreboardEntry, prevClients map[int]extinfo.ClientInfo, flagMode bool) []string {
+	header := pad("name", nameWidth)
+	if flagMode {
+		header += padLeft("flags", numberWidth)
+	}
+	header += padLeft("frags", numberWidth) + padLeft("dths", numberWidth) + padLeft("ping", numberWidth)
+	lines := []string{dim + header + reset}
+
+	for _, c := range players {
+		previous, existed := prevClients[c.ClientNum]
+
+		// highlights value if it changed since the last poll, or the player is new
+		number := func(value, previousValue int) string {
+			cell := padLeft(fmt.Sprint(value), numberWidth)
+			if prevClients != nil && (!existed || value != previousValue) {
+				return reverse + cell + reset
+			}
+			return cell
+		}
+
+		line := privilegeColor(c.Privilege) + pad(colors.Strip(c.Name), nameWidth) + reset
+		if flagMode {
+			line += number(c.Flags, previous.Flags)
+		}
+		line += number(c.Frags, previous.Frags) + number(c.Deaths, previous.Deaths)
+		if c.IsBot {
+			line += padLeft("bot", numberWidth)
+		} else {
+			line += padLeft(fmt.Sprint(c.Ping), numberWidth)
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// returns the color the game uses for names of players with privilege
+func privilegeColor(privilege string) string {
+	switch privilege {
+	case "master":
+		return green
+	case "auth":
+		return magenta
+	case "admin":
+		return yellow
+	default:
+		return ""
+	}
+}
+
+// lays out columns of lines next to each other; all lines are assumed to be width characters wide, not counting escape sequences
+func sideBySide(columns [][]string, width int) string {
+	rows := 0
+	for _, column := range columns {
+		if len(column) > rows {
+			rows = len(column)
+		}
+	}
+
+	b := &strings.Builder{}
+	for row := 0; row < rows; row++ {
+		for i, column := range columns {
+			if i > 0 {
+				b.WriteString("    ")
+			}
+			if row < len(column) {
+				b.WriteString(column[row])
+			} else {
+				b.WriteString(strings.Repeat(" ", width))
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// pads or truncates s to width characters, aligned left
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width-1]) + " "
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// pads s to width characters, aligned right
+func padLeft(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n >= width {
+		return s
+	}
+	return strings.Repeat(" ", width-n) + s
+}
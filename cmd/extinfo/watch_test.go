@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func testSnapshot() *snapshot {
+	return &snapshot{
+		basicInfo: extinfo.BasicInfo{
+			BasicInfoRaw: extinfo.BasicInfoRaw{Map: "forge", SecsLeft: 90, NumberOfClients: 3, MaxNumberOfClients: 16},
+			GameMode:     "insta ctf",
+			MasterMode:   "open",
+		},
+		teamScores: &extinfo.TeamScores{
+			TeamScoresRaw: extinfo.TeamScoresRaw{Scores: map[string]extinfo.TeamScore{
+				"good": {Name: "good", Score: 1},
+				"evil": {Name: "evil", Score: 2},
+			}},
+		},
+		clients: map[int]extinfo.ClientInfo{
+			0: {ClientInfoRaw: extinfo.ClientInfoRaw{ClientNum: 0, Name: "alice", Team: "good", Frags: 10, Flags: 0}, State: "alive"},
+			1: {ClientInfoRaw: extinfo.ClientInfoRaw{ClientNum: 1, Name: "bob", Team: "good", Frags: 5, Flags: 1}, State: "alive"},
+			2: {ClientInfoRaw: extinfo.ClientInfoRaw{ClientNum: 2, Name: "carol", Team: "evil", Frags: 7}, State: "dead"},
+			3: {ClientInfoRaw: extinfo.ClientInfoRaw{ClientNum: 3, Name: "dave"}, State: "spectator"},
+		},
+	}
+}
+
+func TestRenderScoreboard(t *testing.T) {
+	first := testSnapshot()
+	out := renderScoreboard(first, nil)
+
+	if strings.Contains(out, reverse) {
+		t.Error("first frame should not highlight anything")
+	}
+
+	// evil leads, so its column comes first; bob has more flags than alice, so he's listed first
+	if strings.Index(out, "evil: 2") > strings.Index(out, "good: 1") {
+		t.Error("teams not sorted by score")
+	}
+	if strings.Index(out, "bob") > strings.Index(out, "alice") {
+		t.Error("players not sorted by flags")
+	}
+	if !strings.Contains(out, "spectators:"+reset+" dave") {
+		t.Error("spectator missing")
+	}
+	if !strings.Contains(out, "1:30 left") {
+		t.Error("time left missing")
+	}
+
+	second := testSnapshot()
+	alice := second.clients[0]
+	alice.Frags++
+	second.clients[0] = alice
+	out = renderScoreboard(second, first)
+
+	if !strings.Contains(out, reverse+padLeft("11", numberWidth)+reset) {
+		t.Error("changed frags not highlighted")
+	}
+	if strings.Count(out, reverse) != 1 {
+		t.Errorf("expected exactly one highlight, got %d", strings.Count(out, reverse))
+	}
+}
+
+func TestQuerySnapshotModeChange(t *testing.T) {
+	// basic info still reports insta ctf, but the team scores come from the next game, which is not a team mode
+	state := fakeserver.DefaultState()
+	state.TeamMode = false
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := querySnapshot(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.teamScores != nil || len(snap.clients) != 4 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
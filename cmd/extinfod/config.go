@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sauerbraten/extinfo/alert"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// config is the contents of the configuration file. Sections that are left out are disabled.
+type config struct {
+	Interval time.Duration `yaml:"interval"` // time between polls of each server
+	Timeout  time.Duration `yaml:"timeout"`  // time to wait for a server's response
+	Servers  []string      `yaml:"servers"`  // host[:port] of servers to poll
+	Masters  []string      `yaml:"masters"`  // host[:port] of master servers whose lists of servers to poll
+	Listen   string        `yaml:"listen"`   // address to serve the HTTP API and Prometheus metrics on
+
+	API        *apiConfig        `yaml:"api"`
+	Prometheus *prometheusConfig `yaml:"prometheus"`
+	Archive    *archiveConfig    `yaml:"archive"`
+	Stats      *statsConfig      `yaml:"stats"`
+	Uptime     uptimeConfig      `yaml:"uptime"`
+	Alerts     *alertsConfig     `yaml:"alerts"`
+}
+
+type apiConfig struct {
+	CORS string `yaml:"cors"` // value of the Access-Control-Allow-Origin header; empty to disable CORS
+}
+
+type prometheusConfig struct {
+	Path    string `yaml:"path"`    // defaults to /metrics
+	Clients bool   `yaml:"clients"` // export per-player metrics
+	Teams   bool   `yaml:"teams"`   // export team scores
+}
+
+type archiveConfig struct {
+	Dir       string        `yaml:"dir"`
+	MaxSize   int64         `yaml:"maxSize"`   // bytes
+	MaxAge    time.Duration `yaml:"maxAge"`    //
+	Gzip      bool          `yaml:"gzip"`      //
+	Retention time.Duration `yaml:"retention"` // how long to keep archive files; forever if 0
+}
+
+type statsConfig struct {
+	Path string `yaml:"path"` // SQLite database
+}
+
+type uptimeConfig struct {
+	Retention        time.Duration `yaml:"retention"`        // how long to keep outages and restarts
+	RestartTolerance time.Duration `yaml:"restartTolerance"` // see uptime.RestartTolerance()
+}
+
+type alertsConfig struct {
+	Log      bool         `yaml:"log"`      // log alerts
+	Webhooks []string     `yaml:"webhooks"` // URLs to post alerts to
+	Rules    []ruleConfig `yaml:"rules"`
+}
+
+type ruleConfig struct {
+	Name         string        `yaml:"name"`
+	When         string        `yaml:"when"`         // condition and its arguments, e.g. "mastermode private locked"
+	For          time.Duration `yaml:"for"`          //
+	Polls        int           `yaml:"polls"`        //
+	Servers      []string      `yaml:"servers"`      // host[:port]
+	During       string        `yaml:"during"`       // e.g. "18:00-23:00"
+	TimeZone     string        `yaml:"timezone"`     // of During, e.g. "Europe/Berlin"; local time if empty
+	SkipResolved bool          `yaml:"skipResolved"` //
+}
+
+// loadConfig reads the configuration file at path, fills in defaults and checks the result.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	if err := cfg.check(); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	return cfg, nil
+}
+
+// fills in defaults and returns an error describing the first problem found
+func (cfg *config) check() error {
+	if cfg.Interval == 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 3 * time.Second
+	}
+	if cfg.Interval < 0 || cfg.Timeout < 0 {
+		return errors.New("interval and timeout must be positive")
+	}
+
+	if len(cfg.Servers) == 0 && len(cfg.Masters) == 0 {
+		return errors.New("no servers or masters to poll")
+	}
+
+	if (cfg.API != nil || cfg.Prometheus != nil) && cfg.Listen == "" {
+		return errors.New("listen address needed to serve the API or Prometheus metrics")
+	}
+	if cfg.Prometheus != nil && cfg.Prometheus.Path == "" {
+		cfg.Prometheus.Path = "/metrics"
+	}
+
+	if cfg.Archive != nil && cfg.Archive.Dir == "" {
+		return errors.New("archive: dir missing")
+	}
+	if cfg.Stats != nil && cfg.Stats.Path == "" {
+		return errors.New("stats: path missing")
+	}
+
+	if cfg.Alerts != nil {
+		for _, hook := range cfg.Alerts.Webhooks {
+			if u, err := url.Parse(hook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return errors.New("alerts: invalid webhook URL " + strconv.Quote(hook))
+			}
+		}
+		for i, r := range cfg.Alerts.Rules {
+			if _, err := r.rule(); err != nil {
+				return errors.New("alerts: rule " + strconv.Itoa(i+1) + ": " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// rules returns the configured alert rules, with the servers they apply to resolved to the addresses the poller uses.
+func (cfg *alertsConfig) rules() ([]alert.Rule, error) {
+	rules := make([]alert.Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rule, err := r.rule()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hostPort := range r.Servers {
+			addr, err := hostport.Resolve(hostPort)
+			if err != nil {
+				return nil, errors.New(r.Name + ": " + err.Error())
+			}
+			rule.Servers = append(rule.Servers, addr.String())
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// returns the rule without its servers, which are resolved by rules()
+func (r ruleConfig) rule() (rule alert.Rule, err error) {
+	if r.Name == "" {
+		return rule, errors.New("name missing")
+	}
+
+	rule = alert.Rule{
+		Name:         r.Name,
+		For:          r.For,
+		Polls:        r.Polls,
+		SkipResolved: r.SkipResolved,
+	}
+
+	rule.When, err = parseCondition(r.When)
+	if err != nil {
+		return rule, errors.New(r.Name + ": " + err.Error())
+	}
+
+	if r.During != "" {
+		rule.During, err = parseHours(r.During, r.TimeZone)
+		if err != nil {
+			return rule, errors.New(r.Name + ": " + err.Error())
+		}
+	}
+
+	return rule, nil
+}
+
+// parses a condition name followed by its arguments, separated by spaces
+func parseCondition(when string) (alert.Condition, error) {
+	fields := strings.Fields(when)
+	if len(fields) == 0 {
+		return nil, errors.New("condition missing")
+	}
+	name, args := fields[0], fields[1:]
+
+	needArgs := func(n int) error {
+		if len(args) < n {
+			return errors.New(name + " needs an argument")
+		}
+		if n == 0 && len(args) > 0 {
+			return errors.New(name + " takes no arguments")
+		}
+		return nil
+	}
+
+	switch name {
+	case "offline":
+		return alert.Offline(), needArgs(0)
+	case "full":
+		return alert.Full(), needArgs(0)
+	case "mastermode":
+		return alert.MasterMode(args...), needArgs(1)
+	case "privilege":
+		return alert.Privilege(args...), needArgs(1)
+	case "teamkills":
+		if len(args) != 1 {
+			return nil, errors.New("teamkills needs exactly one argument")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return nil, errors.New("invalid number of teamkills " + strconv.Quote(args[0]))
+		}
+		return alert.Teamkills(n), nil
+	case "event":
+		types := make([]poll.EventType, 0, len(args))
+		for _, arg := range args {
+			if !eventTypes[poll.EventType(arg)] {
+				return nil, errors.New("unknown event type " + strconv.Quote(arg))
+			}
+			types = append(types, poll.EventType(arg))
+		}
+		return alert.Event(types...), needArgs(1)
+	default:
+		return nil, errors.New("unknown condition " + strconv.Quote(name))
+	}
+}
+
+// the event types the event condition accepts
+var eventTypes = map[poll.EventType]bool{
+	poll.EventOffline:      true,
+	poll.EventOnline:       true,
+	poll.EventNewGame:      true,
+	poll.EventIntermission: true,
+	poll.EventMasterMode:   true,
+	poll.EventJoin:         true,
+	poll.EventLeave:        true,
+	poll.EventRename:       true,
+	poll.EventTeamChange:   true,
+	poll.EventPrivilege:    true,
+	poll.EventPlayerScore:  true,
+	poll.EventTeamScore:    true,
+}
+
+// parses hours like "18:00-23:00" in the given time zone
+func parseHours(during, timeZone string) (hours alert.Hours, err error) {
+	from, to, ok := strings.Cut(during, "-")
+	if !ok {
+		return hours, errors.New("invalid hours " + strconv.Quote(during) + ", expected e.g. 18:00-23:00")
+	}
+
+	hours.From, err = parseTimeOfDay(strings.TrimSpace(from))
+	if err != nil {
+		return
+	}
+	hours.To, err = parseTimeOfDay(strings.TrimSpace(to))
+	if err != nil {
+		return
+	}
+
+	if timeZone != "" {
+		hours.Location, err = time.LoadLocation(timeZone)
+	}
+	return
+}
+
+// parses 15:04 into the duration since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.New("invalid time of day " + strconv.Quote(s))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
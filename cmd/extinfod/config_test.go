@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/alert"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// writes config to a file in a temporary directory and returns its path
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "extinfod.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, `
+servers: [127.0.0.1:10000, 127.0.0.1]
+listen: ":8080"
+api: {}
+prometheus: {clients: true}
+archive: {dir: ./archive, maxAge: 24h, retention: 720h}
+alerts:
+  log: true
+  webhooks: [https://example.com/hook]
+  rules:
+    - {name: down, when: offline, polls: 3}
+    - {name: private, when: mastermode private locked, during: "18:00-2:30", timezone: UTC, servers: [127.0.0.1]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Interval != 5*time.Second || cfg.Timeout != 3*time.Second || cfg.Prometheus.Path != "/metrics" {
+		t.Errorf("defaults not filled in: %+v", cfg)
+	}
+	if cfg.Archive.MaxAge != 24*time.Hour || cfg.Archive.Retention != 720*time.Hour || cfg.Stats != nil {
+		t.Errorf("unexpected archive and stats config %+v, %+v", cfg.Archive, cfg.Stats)
+	}
+
+	rules, err := cfg.Alerts.rules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Polls != 3 || len(rules[1].Servers) != 1 || rules[1].Servers[0] != "127.0.0.1:28785" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	if expected := (alert.Hours{From: 18 * time.Hour, To: 2*time.Hour + 30*time.Minute, Location: time.UTC}); rules[1].During != expected {
+		t.Errorf("expected hours %+v, got %+v", expected, rules[1].During)
+	}
+
+	snap := &poll.Snapshot{}
+	snap.BasicInfo.MasterMode = "locked"
+	if holds, _ := rules[1].When(poll.Update{State: poll.State{Snapshot: snap}}); !holds {
+		t.Error("mastermode condition does not hold for locked server")
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		config string
+		error  string
+	}{
+		{`listen: ":8080"`, "no servers or masters"},
+		{"servers: [a]\nintervall: 5s", "field intervall not found"},
+		{"servers: [a]\ninterval: 5", "cannot unmarshal"},
+		{"servers: [a]\napi: {}", "listen address needed"},
+		{"servers: [a]\narchive: {gzip: true}", "dir missing"},
+		{"servers: [a]\nalerts: {webhooks: [example.com]}", "invalid webhook URL"},
+		{"servers: [a]\nalerts: {rules: [{when: full}]}", "name missing"},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: empty}]}", `unknown condition "empty"`},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: full now}]}", "takes no arguments"},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: privilege}]}", "needs an argument"},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: teamkills many}]}", "invalid number of teamkills"},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: event explosion}]}", `unknown event type "explosion"`},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: full, during: 18:00}]}", "invalid hours"},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: full, during: 18:00-25:00}]}", "invalid time of day"},
+		{"servers: [a]\nalerts: {rules: [{name: x, when: full, during: 18:00-23:00, timezone: Mars/Olympus}]}", "unknown time zone"},
+	}
+
+	for _, test := range tests {
+		_, err := loadConfig(writeConfig(t, test.config))
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%q: expected error containing %q, got %v", test.config, test.error, err)
+		}
+	}
+}
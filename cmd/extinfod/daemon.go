@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/alert"
+	"github.com/sauerbraten/extinfo/api"
+	"github.com/sauerbraten/extinfo/archive"
+	"github.com/sauerbraten/extinfo/collector"
+	"github.com/sauerbraten/extinfo/internal/hostport"
+	"github.com/sauerbraten/extinfo/internal/master"
+	"github.com/sauerbraten/extinfo/poll"
+	"github.com/sauerbraten/extinfo/stats"
+	"github.com/sauerbraten/extinfo/uptime"
+)
+
+// how long open HTTP requests may take to finish when shutting down or reloading
+const shutdownTimeout = 10 * time.Second
+
+// daemon runs what the configuration file enables, and restarts it with the new configuration on reload.
+type daemon struct {
+	configPath string
+	cfg        *config
+	servers    []*extinfo.Server
+	current    *instance
+
+	// kept across reloads, so availability reports cover more than the time since the last one
+	monitor *uptime.Monitor
+}
+
+// newDaemon loads the configuration file at configPath and starts everything it enables.
+func newDaemon(ctx context.Context, configPath string) (*daemon, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := resolveServers(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &daemon{configPath: configPath}
+	if err := d.start(cfg, servers); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// run waits for reload requests and for ctx to be cancelled, then shuts down gracefully. It returns early if a component fails.
+func (d *daemon) run(ctx context.Context, reload <-chan struct{}) error {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("shutting down")
+			return d.stop()
+		case <-reload:
+			if err := d.reload(ctx); err != nil {
+				d.stop()
+				return err
+			}
+		case err := <-d.current.errs:
+			d.stop()
+			return err
+		}
+	}
+}
+
+// reload reads the configuration file again and restarts everything with the new configuration. If the file is invalid or its servers can't be resolved, everything keeps running as before. If the new configuration fails to start, the previous one is restored. Only errors restoring it are returned.
+func (d *daemon) reload(ctx context.Context) error {
+	cfg, err := loadConfig(d.configPath)
+	if err != nil {
+		log.Println("not reloading:", err)
+		return nil
+	}
+
+	servers, err := resolveServers(ctx, cfg)
+	if err != nil {
+		log.Println("not reloading:", err)
+		return nil
+	}
+
+	prevCfg, prevServers := d.cfg, d.servers
+	if err := d.stop(); err != nil {
+		log.Println("stopping:", err)
+	}
+
+	if err := d.start(cfg, servers); err != nil {
+		log.Println("starting with new configuration failed, restoring the previous one:", err)
+		return d.start(prevCfg, prevServers)
+	}
+
+	log.Println("reloaded", d.configPath)
+	return nil
+}
+
+func (d *daemon) start(cfg *config, servers []*extinfo.Server) error {
+	if cfg.API == nil {
+		d.monitor = nil
+	} else if d.monitor == nil || d.cfg == nil || d.cfg.Uptime != cfg.Uptime {
+		options := []uptime.Option{}
+		if cfg.Uptime.Retention > 0 {
+			options = append(options, uptime.Retention(cfg.Uptime.Retention))
+		}
+		if cfg.Uptime.RestartTolerance > 0 {
+			options = append(options, uptime.RestartTolerance(cfg.Uptime.RestartTolerance))
+		}
+		d.monitor = uptime.New(options...)
+	}
+
+	// start stops what it started before failing, so only running instances are kept
+	inst, err := start(cfg, servers, d.monitor)
+	if err != nil {
+		return err
+	}
+	d.current, d.cfg, d.servers = inst, cfg, servers
+	return nil
+}
+
+func (d *daemon) stop() error {
+	if d.current == nil {
+		return nil
+	}
+	err := d.current.stop()
+	d.current = nil
+	return err
+}
+
+// resolveServers returns the servers listed in cfg and those registered with its masters. Masters that can't be reached are logged and skipped.
+func resolveServers(ctx context.Context, cfg *config) ([]*extinfo.Server, error) {
+	hostPorts := cfg.Servers
+	for _, masterAddr := range cfg.Masters {
+		addrs, err := master.List(ctx, masterAddr, cfg.Timeout)
+		if err != nil {
+			log.Println("fetching servers from master:", err)
+			continue
+		}
+		hostPorts = append(hostPorts, addrs...)
+	}
+
+	servers := []*extinfo.Server{}
+	seen := map[string]bool{}
+	for _, hostPort := range hostPorts {
+		addr, err := hostport.Resolve(hostPort)
+		if err != nil {
+			return nil, err
+		}
+		if seen[addr.String()] {
+			continue
+		}
+		seen[addr.String()] = true
+
+		s, err := extinfo.NewServer(*addr, cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, s)
+	}
+
+	if len(servers) == 0 {
+		return nil, errors.New("no servers to poll")
+	}
+
+	return servers, nil
+}
+
+// instance is one run of the components a configuration enables.
+type instance struct {
+	cancelPolling context.CancelFunc
+	polling       sync.WaitGroup
+	unsubscribes  []func()
+	followers     sync.WaitGroup
+	cancel        context.CancelFunc // of everything else
+	errs          chan error         // receives the first error making a component stop
+
+	httpServer *http.Server
+	addr       net.Addr // the HTTP server listens on
+	archive    *archive.Writer
+	stats      *stats.DB
+}
+
+// start starts polling servers and everything cfg enables.
+func start(cfg *config, servers []*extinfo.Server, monitor *uptime.Monitor) (inst *instance, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pollCtx, cancelPolling := context.WithCancel(ctx)
+	inst = &instance{cancelPolling: cancelPolling, cancel: cancel, errs: make(chan error, 1)}
+	defer func() {
+		if err != nil {
+			inst.stop()
+		}
+	}()
+
+	poller := poll.New(cfg.Interval, servers...)
+
+	// subscribes before the poller starts, so no update is missed, not even when a follower falls behind
+	follow := func(name string, f func(context.Context, <-chan poll.Update) error) {
+		updates, unsubscribe := poller.Subscribe(poll.Lossless())
+		inst.unsubscribes = append(inst.unsubscribes, unsubscribe)
+		inst.followers.Add(1)
+		go func() {
+			defer inst.followers.Done()
+			if err := f(ctx, updates); err != nil {
+				inst.fail(errors.New(name + ": " + err.Error()))
+			}
+			// a failed follower's updates would otherwise be queued forever
+			unsubscribe()
+			for range updates {
+			}
+		}()
+	}
+
+	if cfg.Archive != nil {
+		inst.archive, err = archive.NewWriter(archive.Options{
+			Dir:       cfg.Archive.Dir,
+			MaxSize:   cfg.Archive.MaxSize,
+			MaxAge:    cfg.Archive.MaxAge,
+			Gzip:      cfg.Archive.Gzip,
+			Retention: cfg.Archive.Retention,
+		})
+		if err != nil {
+			return
+		}
+		follow("archive", inst.archive.Follow)
+	}
+
+	if cfg.Stats != nil {
+		inst.stats, err = stats.Open(cfg.Stats.Path)
+		if err != nil {
+			return
+		}
+		follow("stats", inst.stats.Follow)
+	}
+
+	if cfg.Alerts != nil {
+		var rules []alert.Rule
+		rules, err = cfg.Alerts.rules()
+		if err != nil {
+			return
+		}
+
+		notifiers := []alert.Notifier{}
+		if cfg.Alerts.Log {
+			notifiers = append(notifiers, alert.Log(log.Default()))
+		}
+		for _, url := range cfg.Alerts.Webhooks {
+			notifiers = append(notifiers, alert.Webhook(url))
+		}
+
+		engine := alert.New(rules, notifiers...)
+		follow("alerts", func(ctx context.Context, updates <-chan poll.Update) error {
+			engine.Follow(ctx, updates)
+			return nil
+		})
+	}
+
+	if monitor != nil {
+		follow("uptime", func(ctx context.Context, updates <-chan poll.Update) error {
+			monitor.Follow(ctx, updates)
+			return nil
+		})
+	}
+
+	if cfg.API != nil || cfg.Prometheus != nil {
+		mux := http.NewServeMux()
+		if cfg.Prometheus != nil {
+			registry := prometheus.NewRegistry()
+			// the servers are polled anyway, so scrapes don't query them again
+			registry.MustRegister(collector.NewPolled(collector.Options{Uptime: true, ClientInfo: cfg.Prometheus.Clients, TeamScores: cfg.Prometheus.Teams}, poller))
+			mux.Handle(cfg.Prometheus.Path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		}
+		if cfg.API != nil {
+			mux.Handle("/", api.New(poller, api.Options{
+				AllowOrigin: cfg.API.CORS,
+				MaxAge:      cfg.Interval,
+				Uptime:      monitor,
+			}))
+		}
+
+		var listener net.Listener
+		listener, err = net.Listen("tcp", cfg.Listen)
+		if err != nil {
+			return
+		}
+		inst.addr = listener.Addr()
+
+		// event streams end when ctx is cancelled, instead of holding up the shutdown
+		inst.httpServer = &http.Server{Handler: mux, BaseContext: func(net.Listener) context.Context { return ctx }}
+		go func() {
+			if err := inst.httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				inst.fail(err)
+			}
+		}()
+		log.Println("listening on", inst.addr)
+	}
+
+	inst.polling.Add(1)
+	go func() {
+		defer inst.polling.Done()
+		poller.Run(pollCtx)
+	}()
+
+	return inst, nil
+}
+
+// passes err on, unless an earlier error was passed on already
+func (inst *instance) fail(err error) {
+	select {
+	case inst.errs <- err:
+	default:
+	}
+}
+
+// stop stops polling, waits for the results of the last polls to be archived, recorded and evaluated, lets HTTP requests finish and closes the archive and database.
+func (inst *instance) stop() error {
+	inst.cancelPolling()
+	inst.polling.Wait()
+
+	// followers return once they received everything
+	for _, unsubscribe := range inst.unsubscribes {
+		unsubscribe()
+	}
+	inst.followers.Wait()
+
+	inst.cancel()
+
+	var err error
+	if inst.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = inst.httpServer.Shutdown(ctx)
+	}
+
+	if inst.archive != nil {
+		if closeErr := inst.archive.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if inst.stats != nil {
+		if closeErr := inst.stats.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
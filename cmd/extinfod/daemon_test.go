@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/archive"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func startFake(t *testing.T) net.UDPAddr {
+	t.Helper()
+
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fake.Close() })
+
+	return fake.Addr()
+}
+
+func httpGet(t *testing.T, url string) (status int, body string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestDaemon(t *testing.T) {
+	listedAddr, fromMasterAddr := startFake(t), startFake(t)
+	listed, fromMaster := listedAddr.String(), fromMasterAddr.String()
+
+	m, err := fakeserver.StartMaster(fromMasterAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	archiveDir := t.TempDir()
+
+	path := writeConfig(t, `
+interval: 1m
+servers: [`+listed+`]
+masters: [`+m.Addr()+`]
+listen: 127.0.0.1:0
+api: {}
+prometheus: {}
+archive: {dir: `+archiveDir+`}
+alerts:
+  rules: [{name: full, when: full}]
+`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d, err := newDaemon(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + d.current.addr.String()
+
+	if status, body := httpGet(t, base+"/servers"); status != http.StatusOK || !strings.Contains(body, listed) || !strings.Contains(body, fromMaster) {
+		t.Errorf("expected both servers to be listed, got %d: %s", status, body)
+	}
+	// metrics are those of the last poll, so the first one has to finish
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		status, body := httpGet(t, base+"/metrics")
+		if status == http.StatusOK && strings.Contains(body, "sauerbraten_up{server=\""+listed+"\"} 1") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected metrics %d: %s", status, body)
+		}
+	}
+
+	// an invalid configuration is not applied
+	if err := os.WriteFile(path, []byte("interval: soon"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := httpGet(t, base+"/servers"); status != http.StatusOK {
+		t.Errorf("expected daemon to keep running with previous configuration, got %d", status)
+	}
+
+	if err := os.WriteFile(path, []byte("servers: ["+listed+"]\nlisten: 127.0.0.1:0\nprometheus: {path: /prometheus}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	base = "http://" + d.current.addr.String()
+	if status, _ := httpGet(t, base+"/servers"); status != http.StatusNotFound {
+		t.Errorf("expected API to be disabled, got %d", status)
+	}
+	if status, body := httpGet(t, base+"/prometheus"); status != http.StatusOK || strings.Contains(body, fromMaster) {
+		t.Errorf("unexpected metrics after reload %d: %s", status, body)
+	}
+
+	files, err := archive.Files(archiveDir)
+	if err != nil || len(files) != 1 {
+		t.Errorf("expected one archive file, got %v (%v)", files, err)
+	}
+
+	done := make(chan error)
+	go func() { done <- d.run(ctx, nil) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not shut down")
+	}
+}
+
+func TestReloadFailure(t *testing.T) {
+	addr := startFake(t)
+
+	// the new configuration's HTTP server can't listen there
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	statsDir := t.TempDir()
+	path := writeConfig(t, "servers: ["+addr.String()+"]\nlisten: 127.0.0.1:0\napi: {}\nstats: {path: "+statsDir+"/stats.db}\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d, err := newDaemon(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the previous configuration is restored
+	if err := os.WriteFile(path, []byte("servers: ["+addr.String()+"]\nlisten: "+taken.Addr().String()+"\napi: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d.current == nil || d.cfg.Stats == nil {
+		t.Fatal("expected previous configuration to be running")
+	}
+	if status, _ := httpGet(t, "http://"+d.current.addr.String()+"/servers"); status != http.StatusOK {
+		t.Errorf("expected API of previous configuration, got %d", status)
+	}
+
+	// the previous configuration can't be restored either, since its database can't be created anymore
+	if err := os.RemoveAll(statsDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.reload(ctx); err == nil {
+		t.Fatal("expected error restoring the previous configuration")
+	}
+	if d.current != nil {
+		t.Error("expected instance that failed to start not to be kept")
+	}
+	// as run() does after reload() failed
+	if err := d.stop(); err != nil {
+		t.Error(err)
+	}
+}
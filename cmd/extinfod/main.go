@@ -0,0 +1,97 @@
+// Command extinfod is a monitoring daemon combining what extinfo-api, extinfo-exporter and the archive, stats, alert and uptime packages do, configured by a YAML file.
+//
+// Usage:
+//
+//	extinfod [-config extinfod.yaml] [-check]
+//
+// An example configuration, where every section but servers (or masters) is optional and disabled if left out:
+//
+//	interval: 5s                     # time between polls of each server
+//	timeout: 3s                      # time to wait for a server's response
+//	servers:                         # host[:port], the port defaults to 28785
+//	  - sauerleague.org:10000
+//	  - sauerleague.org:20000
+//	masters:                         # poll every server registered with these master servers, port defaults to 28787
+//	  - master.sauerbraten.org
+//	listen: ":8080"                  # where to serve the API and Prometheus metrics
+//	api:                             # JSON API and server-sent events, see package github.com/sauerbraten/extinfo/api
+//	  cors: "*"
+//	prometheus:                      # metrics of all servers, as of their last poll
+//	  path: /metrics
+//	  clients: true                  # per-player metrics
+//	  teams: true                    # team scores
+//	archive:                         # newline-delimited JSON, see package github.com/sauerbraten/extinfo/archive
+//	  dir: ./archive
+//	  maxSize: 104857600
+//	  maxAge: 24h
+//	  gzip: true
+//	  retention: 2160h               # delete older files
+//	stats:                           # games and player statistics, see package github.com/sauerbraten/extinfo/stats
+//	  path: stats.db
+//	uptime:                          # served at /servers/{addr}/availability if the API is enabled
+//	  retention: 2160h
+//	alerts:
+//	  log: true
+//	  webhooks:
+//	    - https://example.com/hook
+//	  rules:
+//	    - {name: full, when: full, for: 5m}
+//	    - {name: down, when: offline, polls: 3}
+//	    - {name: admin, when: privilege admin}
+//	    - {name: private, when: mastermode private locked, during: "18:00-23:00", timezone: Europe/Berlin}
+//	    - {name: teamkiller, when: teamkills 5, servers: [sauerleague.org:10000]}
+//	    - {name: joins, when: event join, skipResolved: true}
+//
+// Alert conditions are offline, full, mastermode <mode>..., privilege <privilege>..., teamkills <n> and event <type>...; see package github.com/sauerbraten/extinfo/alert.
+//
+// On SIGHUP, extinfod reads the configuration file again (and asks the masters for their servers again) and restarts with the new configuration; if the file is invalid, it keeps running with the previous one. Alert states are reset on reload, so alerts still firing fire again. On SIGINT or SIGTERM, it stops polling, lets HTTP requests finish and closes the archive and database.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	configPath := flag.String("config", "extinfod.yaml", "configuration file")
+	check := flag.Bool("check", false, "only check the configuration file and exit")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: extinfod [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *check {
+		if _, err := loadConfig(*configPath); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(*configPath, "is valid")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	reload := make(chan struct{})
+	go func() {
+		for range hangups {
+			reload <- struct{}{}
+		}
+	}()
+
+	d, err := newDaemon(ctx, *configPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := d.run(ctx, reload); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package collector provides Prometheus collectors exporting the state of Sauerbraten game servers, which are either queried on every scrape, or polled by a poll.Poller in the meantime.
+package collector
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sauerbraten/extinfo"
+)
+
+const namespace = "sauerbraten"
+
+var (
+	serverLabels = []string{"server"}
+	teamLabels   = []string{"server", "team"}
+	playerLabels = []string{"server", "cn", "name", "team"}
+
+	upDesc            = newDesc("up", "Whether the last query of the server succeeded.", serverLabels)
+	queryDurationDesc = newDesc("query_duration_seconds", "How long querying the server took.", serverLabels)
+	infoDesc          = newDesc("server_info", "Information about the server and the current game; always 1.", []string{"server", "description", "map", "mode", "mastermode", "mod"})
+	clientsDesc       = newDesc("clients", "Number of clients connected to the server, as reported by the server (excludes bots).", serverLabels)
+	playersDesc       = newDesc("players", "Number of human clients playing, i.e. not spectating.", serverLabels)
+	spectatorsDesc    = newDesc("spectators", "Number of human clients spectating.", serverLabels)
+	botsDesc          = newDesc("bots", "Number of bots.", serverLabels)
+	maxClientsDesc    = newDesc("max_clients", "Maximum number of clients the server allows.", serverLabels)
+	secondsLeftDesc   = newDesc("seconds_left", "Seconds left until intermission.", serverLabels)
+	pausedDesc        = newDesc("paused", "Whether the game is paused.", serverLabels)
+	gameSpeedDesc     = newDesc("game_speed", "Game speed in percent.", serverLabels)
+	uptimeDesc        = newDesc("uptime_seconds", "Seconds since the server was started.", serverLabels)
+	teamScoreDesc     = newDesc("team_score", "Score of the team: flags in ctf modes, frags in deathmatch modes, points in capture, skulls in collect.", teamLabels)
+	playerFragsDesc   = newDesc("player_frags", "Frags of the player in the current game.", playerLabels)
+	playerDeathsDesc  = newDesc("player_deaths", "Deaths of the player in the current game.", playerLabels)
+	playerFlagsDesc   = newDesc("player_flags", "Flags the player scored in the current game.", playerLabels)
+	playerPingDesc    = newDesc("player_ping_seconds", "Ping of the player to the server.", playerLabels)
+)
+
+func newDesc(name, help string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+}
+
+// Options control what is queried in addition to the basic info.
+type Options struct {
+	Uptime     bool // query the server's uptime and mod
+	ClientInfo bool // query every client's info, to export per-player metrics and separate player, spectator and bot counts
+	TeamScores bool // query the team scores when a team mode is played
+}
+
+// AllOptions queries everything there is to export.
+var AllOptions = Options{Uptime: true, ClientInfo: true, TeamScores: true}
+
+// Collector queries its servers whenever it is collected and exports their state as metrics. Servers that can't be queried are reported with sauerbraten_up set to 0.
+type Collector struct {
+	servers []*extinfo.Server
+	options Options
+}
+
+// New returns a Collector querying servers as specified by options.
+func New(options Options, servers ...*extinfo.Server) *Collector {
+	return &Collector{
+		servers: servers,
+		options: options,
+	}
+}
+
+// the descriptions of all metrics query() returns
+var serverDescs = []*prometheus.Desc{
+	infoDesc,
+	clientsDesc, playersDesc, spectatorsDesc, botsDesc, maxClientsDesc,
+	secondsLeftDesc, pausedDesc, gameSpeedDesc, uptimeDesc, teamScoreDesc,
+	playerFragsDesc, playerDeathsDesc, playerFlagsDesc, playerPingDesc,
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- queryDurationDesc
+	for _, desc := range serverDescs {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector. All servers are queried concurrently.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	wg := sync.WaitGroup{}
+	for _, s := range c.servers {
+		wg.Add(1)
+		go func(s *extinfo.Server) {
+			defer wg.Done()
+			c.collectServer(ch, s)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// queries s and sends its metrics to ch
+func (c *Collector) collectServer(ch chan<- prometheus.Metric, s *extinfo.Server) {
+	addr := s.Addr()
+	server := addr.String()
+
+	start := time.Now()
+	metrics, err := query(s, c.options)
+	duration := time.Since(start)
+
+	up := 0.0
+	if err == nil {
+		up = 1
+		for _, m := range metrics {
+			ch <- m
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up, server)
+	ch <- prometheus.MustNewConstMetric(queryDurationDesc, prometheus.GaugeValue, duration.Seconds(), server)
+}
+
+// what the metrics of a server are made from; fields not queried are left empty
+type serverState struct {
+	basicInfo  extinfo.BasicInfo
+	uptime     int
+	mod        string
+	teamScores *extinfo.TeamScores // nil outside of team modes
+	clients    map[int]extinfo.ClientInfo
+}
+
+// queries s as specified by options and returns the resulting metrics, or an error if any of the queries failed.
+func query(s *extinfo.Server, options Options) (metrics []prometheus.Metric, err error) {
+	addr := s.Addr()
+	st := serverState{}
+
+	st.basicInfo, err = s.GetBasicInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Uptime {
+		st.uptime, err = s.GetUptime()
+		if err != nil {
+			return nil, err
+		}
+
+		st.mod, err = s.GetServerMod()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.TeamScores && extinfo.IsTeamMode(st.basicInfo.GameMode) {
+		var teamScores extinfo.TeamScores
+		teamScores, err = s.GetTeamScores()
+		// the game mode might have changed in the meantime
+		if err != nil && !errors.Is(err, extinfo.ErrNotTeamMode) {
+			return nil, err
+		}
+		if err == nil {
+			st.teamScores = &teamScores
+		}
+	}
+
+	if options.ClientInfo {
+		st.clients, err = s.GetAllClientInfo()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return st.metrics(addr.String(), options), nil
+}
+
+// returns the metrics of server in state st, as selected by options
+func (st *serverState) metrics(server string, options Options) (metrics []prometheus.Metric) {
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		metrics = append(metrics, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append([]string{server}, labels...)...))
+	}
+
+	basicInfo := st.basicInfo
+	if options.Uptime {
+		gauge(uptimeDesc, float64(st.uptime))
+	}
+
+	gauge(infoDesc, 1, basicInfo.Description, basicInfo.Map, basicInfo.GameMode, basicInfo.MasterMode, st.mod)
+	gauge(clientsDesc, float64(basicInfo.NumberOfClients))
+	gauge(maxClientsDesc, float64(basicInfo.MaxNumberOfClients))
+	gauge(secondsLeftDesc, float64(basicInfo.SecsLeft))
+	gauge(pausedDesc, boolToFloat(basicInfo.Paused))
+	gauge(gameSpeedDesc, float64(basicInfo.GameSpeed))
+
+	if options.TeamScores && st.teamScores != nil {
+		for _, score := range st.teamScores.Scores {
+			gauge(teamScoreDesc, float64(score.Score), score.Name)
+		}
+	}
+
+	if options.ClientInfo {
+		counts := extinfo.CountClients(st.clients)
+		gauge(playersDesc, float64(counts.Humans-counts.Spectators))
+		gauge(spectatorsDesc, float64(counts.Spectators))
+		gauge(botsDesc, float64(counts.Bots))
+
+		for cn, clientInfo := range st.clients {
+			labels := []string{strconv.Itoa(cn), clientInfo.Name, clientInfo.Team}
+			gauge(playerFragsDesc, float64(clientInfo.Frags), labels...)
+			gauge(playerDeathsDesc, float64(clientInfo.Deaths), labels...)
+			gauge(playerFlagsDesc, float64(clientInfo.Flags), labels...)
+			gauge(playerPingDesc, float64(clientInfo.Ping)/1000, labels...)
+		}
+	}
+
+	return
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+func TestCollector(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// queries go to port 1, where nothing listens
+	unreachable, err := extinfo.NewServer(net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fake.Addr()
+	server := addr.String()
+	addr = unreachable.Addr()
+	other := addr.String()
+
+	expected := fmt.Sprintf(`
+# HELP sauerbraten_up Whether the last query of the server succeeded.
+# TYPE sauerbraten_up gauge
+sauerbraten_up{server=%[1]q} 1
+sauerbraten_up{server=%[2]q} 0
+# HELP sauerbraten_server_info Information about the server and the current game; always 1.
+# TYPE sauerbraten_server_info gauge
+sauerbraten_server_info{description="fake server",map="forge",mastermode="open",mod="",mode="insta ctf",server=%[1]q} 1
+# HELP sauerbraten_players Number of human clients playing, i.e. not spectating.
+# TYPE sauerbraten_players gauge
+sauerbraten_players{server=%[1]q} 2
+# HELP sauerbraten_spectators Number of human clients spectating.
+# TYPE sauerbraten_spectators gauge
+sauerbraten_spectators{server=%[1]q} 1
+# HELP sauerbraten_bots Number of bots.
+# TYPE sauerbraten_bots gauge
+sauerbraten_bots{server=%[1]q} 1
+# HELP sauerbraten_uptime_seconds Seconds since the server was started.
+# TYPE sauerbraten_uptime_seconds gauge
+sauerbraten_uptime_seconds{server=%[1]q} 3600
+# HELP sauerbraten_team_score Score of the team: flags in ctf modes, frags in deathmatch modes, points in capture, skulls in collect.
+# TYPE sauerbraten_team_score gauge
+sauerbraten_team_score{server=%[1]q,team="evil"} 1
+sauerbraten_team_score{server=%[1]q,team="good"} 3
+# HELP sauerbraten_player_frags Frags of the player in the current game.
+# TYPE sauerbraten_player_frags gauge
+sauerbraten_player_frags{cn="0",name="alice",server=%[1]q,team="good"} 20
+sauerbraten_player_frags{cn="10",name="bob",server=%[1]q,team="evil"} 12
+sauerbraten_player_frags{cn="11",name="carol",server=%[1]q,team="good"} 0
+sauerbraten_player_frags{cn="128",name="bot",server=%[1]q,team="evil"} 5
+`, server, other)
+
+	c := New(AllOptions, s, unreachable)
+	err = testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"sauerbraten_up", "sauerbraten_server_info", "sauerbraten_players", "sauerbraten_spectators", "sauerbraten_bots",
+		"sauerbraten_uptime_seconds", "sauerbraten_team_score", "sauerbraten_player_frags")
+	if err != nil {
+		t.Error(err)
+	}
+
+	problems, err := testutil.CollectAndLint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Errorf("%s: %s", problem.Metric, problem.Text)
+	}
+}
+
+func TestPolled(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unreachable, err := extinfo.NewServer(net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := fake.Addr()
+	server := addr.String()
+	addr = unreachable.Addr()
+	other := addr.String()
+
+	p := poll.New(time.Minute, s, unreachable)
+	p.PollAll()
+
+	// scrapes don't query the servers
+	fake.Close()
+
+	expected := fmt.Sprintf(`
+# HELP sauerbraten_up Whether the last query of the server succeeded.
+# TYPE sauerbraten_up gauge
+sauerbraten_up{server=%[1]q} 1
+sauerbraten_up{server=%[2]q} 0
+# HELP sauerbraten_server_info Information about the server and the current game; always 1.
+# TYPE sauerbraten_server_info gauge
+sauerbraten_server_info{description="fake server",map="forge",mastermode="open",mod="",mode="insta ctf",server=%[1]q} 1
+# HELP sauerbraten_uptime_seconds Seconds since the server was started.
+# TYPE sauerbraten_uptime_seconds gauge
+sauerbraten_uptime_seconds{server=%[1]q} 3600
+# HELP sauerbraten_team_score Score of the team: flags in ctf modes, frags in deathmatch modes, points in capture, skulls in collect.
+# TYPE sauerbraten_team_score gauge
+sauerbraten_team_score{server=%[1]q,team="evil"} 1
+sauerbraten_team_score{server=%[1]q,team="good"} 3
+`, server, other)
+
+	c := NewPolled(Options{Uptime: true, TeamScores: true}, p)
+	err = testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"sauerbraten_up", "sauerbraten_server_info", "sauerbraten_players", "sauerbraten_uptime_seconds", "sauerbraten_team_score", "sauerbraten_player_frags")
+	if err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c, "sauerbraten_last_poll_timestamp_seconds"); n != 2 {
+		t.Errorf("expected 2 poll timestamps, got %d", n)
+	}
+
+	problems, err := testutil.CollectAndLint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Errorf("%s: %s", problem.Metric, problem.Text)
+	}
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+var lastPollDesc = newDesc("last_poll_timestamp_seconds", "When the server was last polled, as Unix time.", serverLabels)
+
+// Polled exports the states a poll.Poller keeps of its servers, instead of querying them on every scrape. sauerbraten_up reflects whether the last poll succeeded, and sauerbraten_last_poll_timestamp_seconds takes the place of sauerbraten_query_duration_seconds.
+type Polled struct {
+	poller  *poll.Poller
+	options Options
+}
+
+// NewPolled returns a Polled exporting the states kept by poller. Since pollers query everything, options only select which of it is exported.
+func NewPolled(options Options, poller *poll.Poller) *Polled {
+	return &Polled{
+		poller:  poller,
+		options: options,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (p *Polled) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- lastPollDesc
+	for _, desc := range serverDescs {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (p *Polled) Collect(ch chan<- prometheus.Metric) {
+	for _, state := range p.poller.States() {
+		up := 0.0
+		if snap := state.Snapshot; snap != nil && state.LastError == "" {
+			up = 1
+
+			// metrics have the description without color codes, like when querying the server
+			st := serverState{
+				basicInfo:  snap.BasicInfo,
+				uptime:     snap.Uptime,
+				mod:        snap.Mod,
+				teamScores: snap.TeamScores,
+				clients:    snap.Clients,
+			}
+			st.basicInfo.Description = snap.Description()
+
+			for _, m := range st.metrics(state.Addr, p.options) {
+				ch <- m
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up, state.Addr)
+		if !state.LastPoll.IsZero() {
+			ch <- prometheus.MustNewConstMetric(lastPollDesc, prometheus.GaugeValue, float64(state.LastPoll.UnixNano())/1e9, state.Addr)
+		}
+	}
+}
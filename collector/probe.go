@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sauerbraten/extinfo"
+)
+
+var (
+	probeSuccessDesc  = prometheus.NewDesc("probe_success", "Whether querying the server succeeded.", nil, nil)
+	probeDurationDesc = prometheus.NewDesc("probe_duration_seconds", "How long querying the server took.", nil, nil)
+)
+
+// Probe queries a single server when collected, in the style of blackbox_exporter: instead of sauerbraten_up and sauerbraten_query_duration_seconds, the outcome is reported as probe_success and probe_duration_seconds without any labels. It is meant to be registered with a fresh registry for every probe request.
+type Probe struct {
+	server  *extinfo.Server
+	options Options
+
+	// Err is the error that made the last query fail, if any.
+	Err error
+}
+
+// NewProbe returns a Probe querying s as specified by options.
+func NewProbe(s *extinfo.Server, options Options) *Probe {
+	return &Probe{
+		server:  s,
+		options: options,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (p *Probe) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeSuccessDesc
+	ch <- probeDurationDesc
+	for _, desc := range serverDescs {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (p *Probe) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	metrics, err := query(p.server, p.options)
+	duration := time.Since(start)
+
+	p.Err = err
+
+	success := 0.0
+	if err == nil {
+		success = 1
+		for _, m := range metrics {
+			ch <- m
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(probeSuccessDesc, prometheus.GaugeValue, success)
+	ch <- prometheus.MustNewConstMetric(probeDurationDesc, prometheus.GaugeValue, duration.Seconds())
+}
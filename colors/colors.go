@@ -0,0 +1,134 @@
+// Package colors renders the color codes Cube 2: Sauerbraten uses in strings (e.g. server descriptions) for terminals and web pages.
+//
+// A color code is a form feed followed by a digit from 0 to 7, e.g. "\f3" switches to red. "\fs" saves the current color and "\fr" restores the last saved one. Any other character following a form feed is ignored, as the game does.
+package colors
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"unicode/utf8"
+)
+
+// RGB is a color.
+type RGB struct {
+	R, G, B uint8
+}
+
+// Hex returns c in CSS notation, e.g. "#ff4040".
+func (c RGB) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Palette contains the colors of the codes \f0 to \f7, as the game renders them.
+var Palette = [8]RGB{
+	{64, 255, 128},  // \f0 green
+	{96, 160, 255},  // \f1 blue
+	{255, 192, 64},  // \f2 yellow
+	{255, 64, 64},   // \f3 red
+	{128, 128, 128}, // \f4 gray
+	{192, 64, 192},  // \f5 magenta
+	{255, 128, 0},   // \f6 orange
+	{255, 255, 255}, // \f7 white
+}
+
+// a run of text in one color; color is -1 for text before any color code
+type segment struct {
+	color int
+	text  string
+}
+
+// splits s into runs of the same color
+func parse(s string) (segments []segment) {
+	color, saved := -1, []int{}
+	text := strings.Builder{}
+
+	flush := func() {
+		if text.Len() > 0 {
+			segments = append(segments, segment{color: color, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\f' {
+			text.WriteByte(s[i])
+			continue
+		}
+
+		// form feed at the end of the string
+		if i+1 == len(s) {
+			break
+		}
+
+		code, size := utf8.DecodeRuneInString(s[i+1:])
+		i += size
+
+		newColor := color
+		switch {
+		case code >= '0' && code <= '7':
+			newColor = int(code - '0')
+		case code == 's':
+			saved = append(saved, color)
+		case code == 'r':
+			// like in game, there is nothing to restore without a saved color
+			if len(saved) > 0 {
+				newColor = saved[len(saved)-1]
+				saved = saved[:len(saved)-1]
+			}
+		}
+
+		if newColor != color {
+			flush()
+			color = newColor
+		}
+	}
+	flush()
+
+	return
+}
+
+// Strip returns s without color codes. Unlike cubecode.SanitizeString, it does not trim whitespace.
+func Strip(s string) string {
+	b := strings.Builder{}
+	for _, seg := range parse(s) {
+		b.WriteString(seg.text)
+	}
+	return b.String()
+}
+
+// ANSI returns s with color codes replaced by 24-bit ANSI escape sequences. If s contains color codes, the terminal's color is reset at the end.
+func ANSI(s string) string {
+	b := strings.Builder{}
+	colored := false
+	for _, seg := range parse(s) {
+		if seg.color < 0 {
+			if colored {
+				b.WriteString("\x1b[39m")
+			}
+		} else {
+			c := Palette[seg.color]
+			fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B)
+			colored = true
+		}
+		b.WriteString(seg.text)
+	}
+	if colored {
+		b.WriteString("\x1b[39m")
+	}
+	return b.String()
+}
+
+// HTML returns s HTML-escaped, with colored text wrapped in spans like <span class="cube-color-3" style="color: #ff4040">. Text before the first color code is not wrapped, so it takes the page's text color.
+func HTML(s string) template.HTML {
+	b := strings.Builder{}
+	for _, seg := range parse(s) {
+		if seg.color < 0 {
+			b.WriteString(html.EscapeString(seg.text))
+			continue
+		}
+		fmt.Fprintf(&b, `<span class="cube-color-%d" style="color: %s">%s</span>`, seg.color, Palette[seg.color].Hex(), html.EscapeString(seg.text))
+	}
+	return template.HTML(b.String())
+}
@@ -0,0 +1,33 @@
+package colors
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		in    string
+		strip string
+		ansi  string
+		html  string
+	}{
+		{"plain", "plain", "plain", "plain"},
+		{"\f3red \f7white", "red white", "\x1b[38;2;255;64;64mred \x1b[38;2;255;255;255mwhite\x1b[39m", `<span class="cube-color-3" style="color: #ff4040">red </span><span class="cube-color-7" style="color: #ffffff">white</span>`},
+		{"a\fs\f1b\frc", "abc", "a\x1b[38;2;96;160;255mb\x1b[39mc\x1b[39m", `a<span class="cube-color-1" style="color: #60a0ff">b</span>c`},
+		{"\f0<b>&\fx\fé", "<b>&", "\x1b[38;2;64;255;128m<b>&\x1b[39m", `<span class="cube-color-0" style="color: #40ff80">&lt;b&gt;&amp;</span>`},
+		{"end\f", "end", "end", "end"},
+		{"\f3red\fr still red", "red still red", "\x1b[38;2;255;64;64mred still red\x1b[39m", `<span class="cube-color-3" style="color: #ff4040">red still red</span>`},
+	}
+
+	for _, test := range tests {
+		if out := Strip(test.in); out != test.strip {
+			t.Errorf("Strip(%q) = %q, expected %q", test.in, out, test.strip)
+		}
+		if out := ANSI(test.in); out != test.ansi {
+			t.Errorf("ANSI(%q) = %q, expected %q", test.in, out, test.ansi)
+		}
+		if out := string(HTML(test.in)); out != test.html {
+			t.Errorf("HTML(%q) = %q, expected %q", test.in, out, test.html)
+		}
+	}
+}
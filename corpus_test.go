@@ -0,0 +1,244 @@
+package extinfo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The corpus in testdata/corpus consists of exchanges with servers and, for each set of them, the result of decoding them as golden JSON file. Exchanges are either recordings of real servers (*.ndjson, as written by a Recorder, e.g. using `extinfo -record`), or written by hand (*.txt, see readHandWritten()), byte by byte as the servers' code sends them, so they don't depend on an encoder of ours.
+// To add a capture of a real server, copy the recording of `extinfo -record file.ndjson host:port all` there and run `go test -run TestCorpus -update`.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/corpus")
+
+const corpusDir = "testdata/corpus"
+
+// everything decoded from a recording
+type corpusResult struct {
+	BasicInfo  BasicInfo    `json:"basicInfo"`
+	Uptime     int          `json:"uptime"`
+	Mod        string       `json:"mod"`
+	TeamScores *TeamScores  `json:"teamScores,omitempty"` // nil outside of team modes
+	Clients    []ClientInfo `json:"clients"`              // sorted by CN
+}
+
+// sends every query the corpus covers to s
+func queryCorpus(s *Server) (result corpusResult, err error) {
+	result.BasicInfo, err = s.GetBasicInfo(KeepColorCodes(true))
+	if err != nil {
+		return
+	}
+
+	result.Uptime, err = s.GetUptime()
+	if err != nil {
+		return
+	}
+
+	result.Mod, err = s.GetServerMod()
+	if err != nil {
+		return
+	}
+
+	teamScores, err := s.GetTeamScores()
+	if err == nil {
+		result.TeamScores = &teamScores
+	} else if !errors.Is(err, ErrNotTeamMode) {
+		return
+	}
+
+	allClientInfo, err := s.GetAllClientInfo()
+	if err != nil {
+		return
+	}
+	for _, clientInfo := range allClientInfo {
+		result.Clients = append(result.Clients, clientInfo)
+	}
+	sort.Slice(result.Clients, func(i, j int) bool { return result.Clients[i].ClientNum < result.Clients[j].ClientNum })
+
+	return
+}
+
+// reads hand-written exchanges, listed like this:
+//
+//	server 127.0.0.1:28785   the address players connect to, before all exchanges
+//	request 00 02            starts an exchange
+//	response 00 02 ff 69 00  a datagram of the response; lines starting with white space continue it
+//	  "good" 03 ff           strings are written in quotes (using Go's escape sequences) and sent with their terminating 0
+//
+// Bytes are written in hex; # starts a comment.
+func readHandWritten(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var info *net.UDPAddr
+	exchanges := []*Exchange{}
+	var data *[]byte // the request or datagram the current line continues
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		fail := func(msg string) error { return errors.New(path + ":" + strconv.Itoa(n) + ": " + msg) }
+
+		keyword := ""
+		if line != "" && line[0] != ' ' && line[0] != '\t' && line[0] != '#' {
+			keyword, line, _ = strings.Cut(line, " ")
+		}
+
+		switch keyword {
+		case "":
+		case "server":
+			addr, err := net.ResolveUDPAddr("udp", strings.TrimSpace(line))
+			if err != nil {
+				return nil, fail(err.Error())
+			}
+			addr.Port++
+			info = addr
+			continue
+		case "request":
+			if info == nil {
+				return nil, fail("request before server")
+			}
+			exchanges = append(exchanges, &Exchange{Addr: info.String()})
+			data = &exchanges[len(exchanges)-1].Request
+		case "response":
+			if len(exchanges) == 0 {
+				return nil, fail("response before request")
+			}
+			exchange := exchanges[len(exchanges)-1]
+			exchange.Responses = append(exchange.Responses, Datagram{})
+			data = &exchange.Responses[len(exchange.Responses)-1].Data
+		default:
+			return nil, fail("unknown keyword " + strconv.Quote(keyword))
+		}
+
+		for {
+			line = strings.TrimLeft(line, " \t")
+			if line == "" || line[0] == '#' {
+				break
+			}
+			if data == nil {
+				return nil, fail("bytes outside of request or response")
+			}
+
+			if line[0] == '"' {
+				quoted, err := strconv.QuotedPrefix(line)
+				if err != nil {
+					return nil, fail(err.Error())
+				}
+				str, _ := strconv.Unquote(quoted)
+				*data = append(append(*data, str...), 0)
+				line = line[len(quoted):]
+				continue
+			}
+
+			token, rest, _ := strings.Cut(line, " ")
+			b, err := strconv.ParseUint(token, 16, 8)
+			if err != nil {
+				return nil, fail("invalid byte " + strconv.Quote(token))
+			}
+			*data = append(*data, byte(b))
+			line = rest
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	// goes through the format a Recorder writes, so replaying works exactly like for recordings
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, exchange := range exchanges {
+		if err := enc.Encode(exchange); err != nil {
+			return nil, err
+		}
+	}
+	return NewReplay(buf)
+}
+
+func TestCorpus(t *testing.T) {
+	// shows the data mods append to responses in the golden files, so they prove it is found where it starts
+	for mod := -2; mod >= -10; mod-- {
+		RegisterExtensionParsers(getServerModName(mod), ExtensionParsers{BasicInfo: parseTestExtension, ClientInfo: parseTestExtension})
+		defer RegisterExtensionParsers(getServerModName(mod), ExtensionParsers{})
+	}
+
+	entries, err := os.ReadDir(corpusDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tested := 0
+	for _, entry := range entries {
+		path := filepath.Join(corpusDir, entry.Name())
+		ext := filepath.Ext(path)
+		if ext != ".ndjson" && ext != ".txt" {
+			continue
+		}
+		tested++
+
+		name := strings.TrimSuffix(entry.Name(), ext)
+		t.Run(name, func(t *testing.T) {
+			read := OpenReplay
+			if ext == ".txt" {
+				read = readHandWritten
+			}
+			replay, err := read(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			servers := replay.Servers()
+			if len(servers) != 1 {
+				t.Fatalf("expected recording of 1 server, got %d", len(servers))
+			}
+
+			s, err := NewServer(servers[0], time.Second, UseTransport(replay))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := queryCorpus(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := json.MarshalIndent(result, "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded = append(decoded, '\n')
+
+			golden := filepath.Join(corpusDir, name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, decoded, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, expected) {
+				t.Errorf("decoded response differs from %s (run with -update if that's intended):\n%s", golden, decoded)
+			}
+		})
+	}
+
+	if tested == 0 {
+		t.Fatal("no exchanges in " + corpusDir)
+	}
+}
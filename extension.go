@@ -0,0 +1,172 @@
+package extinfo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/cubecode"
+)
+
+// Extension is the decoded form of the mod-specific data a server mod appends to a response. The concrete type is whatever the registered ExtensionParser returns.
+type Extension interface{}
+
+// ExtensionParser decodes the bytes left in a response after all standard fields were read.
+type ExtensionParser func(remaining *cubecode.Packet) (Extension, error)
+
+// TeamScoresParser separates the data a server mod appends to a team scores response from the list of teams and decodes it. The number of teams is not sent, so only a parser knowing the mod's format can tell where the list ends. It is called with everything following game mode and time left, and returns the part holding the teams.
+type TeamScoresParser func(rest []byte) (teams []byte, extension Extension, err error)
+
+// ExtensionParsers holds the parsers for the responses a server mod extends. Parsers for responses the mod does not extend can be left nil.
+type ExtensionParsers struct {
+	BasicInfo  ExtensionParser
+	ClientInfo ExtensionParser
+	TeamScores TeamScoresParser
+}
+
+var (
+	extensionParsersMutex sync.RWMutex
+	extensionParsers      = map[string]ExtensionParsers{}
+)
+
+// RegisterExtensionParsers registers the parsers to use for servers running mod, where mod is the name as returned by GetServerMod(), e.g. "zeromod". Registering parsers for a mod again replaces the earlier ones.
+func RegisterExtensionParsers(mod string, parsers ExtensionParsers) {
+	extensionParsersMutex.Lock()
+	defer extensionParsersMutex.Unlock()
+
+	extensionParsers[mod] = parsers
+}
+
+// returns the parsers registered for mod, if any
+func getExtensionParsers(mod string) (parsers ExtensionParsers, ok bool) {
+	extensionParsersMutex.RLock()
+	defer extensionParsersMutex.RUnlock()
+
+	parsers, ok = extensionParsers[mod]
+	return
+}
+
+// returns true if parsers have been registered for at least one mod
+func haveExtensionParsers() bool {
+	extensionParsersMutex.RLock()
+	defer extensionParsersMutex.RUnlock()
+
+	return len(extensionParsers) > 0
+}
+
+// how long a detected server mod is assumed not to change
+const modTTL = 5 * time.Minute
+
+// returns the name of the server's mod, querying the server if it wasn't detected in the last modTTL or the server restarted since
+func (s *Server) serverMod() (string, error) {
+	s.modMutex.Lock()
+	defer s.modMutex.Unlock()
+
+	if !s.modDetected.IsZero() && time.Since(s.modDetected) < modTTL {
+		return s.mod, nil
+	}
+
+	uptime, mod, err := s.queryServerMod()
+	if err != nil {
+		return "", err
+	}
+
+	s.mod, s.modDetected, s.uptime = mod, time.Now(), uptime
+	return mod, nil
+}
+
+// notes the uptime the server reported; if it went backwards, the server was restarted and its mod is detected again
+func (s *Server) observeUptime(uptime int) {
+	s.modMutex.Lock()
+	defer s.modMutex.Unlock()
+
+	if uptime < s.uptime {
+		s.modDetected = time.Time{}
+	}
+	s.uptime = uptime
+}
+
+// returns the parsers registered for the server's mod, if any, detecting the mod if necessary
+func (s *Server) modExtensionParsers() (mod string, parsers ExtensionParsers, ok bool, err error) {
+	if !haveExtensionParsers() {
+		return
+	}
+
+	mod, err = s.serverMod()
+	if err != nil {
+		// wrapped, so time outs can still be told apart
+		err = fmt.Errorf("extinfo: error detecting server mod: %w", err)
+		return
+	}
+
+	parsers, ok = getExtensionParsers(mod)
+	return
+}
+
+// turns a panic of a parser registered for mod into an error; parsers get the server's data, so they are just as likely to trip over malformed responses as ours
+func recoverParserPanic(mod string, err *error) {
+	if r := recover(); r != nil {
+		*err = errors.New("extinfo: " + mod + " extension parser panicked: " + fmt.Sprint(r))
+	}
+}
+
+// decodes the data left in response using the parser which choose selects from the parsers registered for the server's mod. The server's mod is only detected if there actually is data left to decode.
+func (s *Server) parseExtension(response *cubecode.Packet, choose func(ExtensionParsers) ExtensionParser) (extension Extension, err error) {
+	if !response.HasRemaining() {
+		return
+	}
+
+	mod, parsers, ok, err := s.modExtensionParsers()
+	if err != nil || !ok {
+		return
+	}
+
+	parser := choose(parsers)
+	if parser == nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			extension = nil
+		}
+	}()
+	defer recoverParserPanic(mod, &err)
+
+	extension, err = parser(response)
+	if err != nil {
+		err = errors.New("extinfo: error parsing " + mod + " extension: " + err.Error())
+	}
+
+	return
+}
+
+// separates the list of teams in response from the data the server's mod appended to it, if a team scores parser is registered for the mod. Otherwise, all of response is the list of teams, as vanilla servers send it.
+func (s *Server) parseTeamScoresExtension(response *cubecode.Packet) (teams *cubecode.Packet, extension Extension, err error) {
+	teams = response
+	if !response.HasRemaining() {
+		return
+	}
+
+	mod, parsers, ok, err := s.modExtensionParsers()
+	if err != nil || !ok || parsers.TeamScores == nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			extension = nil
+		}
+	}()
+	defer recoverParserPanic(mod, &err)
+
+	teamBytes, extension, err := parsers.TeamScores(packetBytes(response))
+	if err != nil {
+		err = errors.New("extinfo: error parsing " + mod + " extension: " + err.Error())
+		return
+	}
+
+	teams = cubecode.NewPacket(teamBytes)
+	return
+}
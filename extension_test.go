@@ -0,0 +1,191 @@
+package extinfo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/cubecode"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+type testExtension struct {
+	Values []int
+}
+
+func parseTestExtension(remaining *cubecode.Packet) (Extension, error) {
+	ext := testExtension{}
+	for remaining.HasRemaining() {
+		value, err := remaining.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		ext.Values = append(ext.Values, value)
+	}
+	return ext, nil
+}
+
+// the test mod appends values to the teams, followed by the number of values
+func parseTestTeamScores(rest []byte) (teams []byte, extension Extension, err error) {
+	if len(rest) == 0 {
+		return nil, nil, errors.New("number of values missing")
+	}
+	n := int(rest[len(rest)-1])
+	if n >= len(rest) {
+		return nil, nil, errors.New("too many values")
+	}
+	teams, values := rest[:len(rest)-1-n], rest[len(rest)-1-n:len(rest)-1]
+
+	extension, err = parseTestExtension(cubecode.NewPacket(values))
+	return teams, extension, err
+}
+
+func TestExtensions(t *testing.T) {
+	RegisterExtensionParsers("zeromod", ExtensionParsers{
+		BasicInfo:  parseTestExtension,
+		TeamScores: parseTestTeamScores,
+	})
+	defer RegisterExtensionParsers("zeromod", ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.Mod = -8
+	state.BasicInfoExtension = []byte{1, 2}
+	state.TeamScoresExtension = []byte{3, 1}
+	s := startFakeServer(t, state)
+
+	basicInfo, err := s.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext, ok := basicInfo.Extension.(testExtension); !ok || len(ext.Values) != 2 || ext.Values[0] != 1 || ext.Values[1] != 2 {
+		t.Errorf("unexpected basic info extension %#v", basicInfo.Extension)
+	}
+
+	teamScores, err := s.GetTeamScores()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(teamScores.Scores) != 2 {
+		t.Errorf("expected 2 teams, got %d", len(teamScores.Scores))
+	}
+	if ext, ok := teamScores.Extension.(testExtension); !ok || len(ext.Values) != 1 || ext.Values[0] != 3 {
+		t.Errorf("unexpected team scores extension %#v", teamScores.Extension)
+	}
+}
+
+func TestExtensionsOtherMod(t *testing.T) {
+	RegisterExtensionParsers("zeromod", ExtensionParsers{BasicInfo: parseTestExtension})
+	defer RegisterExtensionParsers("zeromod", ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.Mod = -4 // spaghettimod
+	state.BasicInfoExtension = []byte{1}
+	s := startFakeServer(t, state)
+
+	basicInfo, err := s.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basicInfo.Extension != nil {
+		t.Errorf("expected no extension, got %#v", basicInfo.Extension)
+	}
+}
+
+func TestExtensionParserError(t *testing.T) {
+	RegisterExtensionParsers("zeromod", ExtensionParsers{
+		BasicInfo: func(*cubecode.Packet) (Extension, error) { return nil, errors.New("broken") },
+	})
+	defer RegisterExtensionParsers("zeromod", ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.Mod = -8
+	state.BasicInfoExtension = []byte{1}
+	s := startFakeServer(t, state)
+
+	if _, err := s.GetBasicInfo(); err == nil {
+		t.Error("expected error from extension parser")
+	}
+}
+
+func TestExtensionParserPanic(t *testing.T) {
+	RegisterExtensionParsers("zeromod", ExtensionParsers{
+		BasicInfo: func(remaining *cubecode.Packet) (Extension, error) {
+			values := []int{}
+			return values[len(values)], nil
+		},
+	})
+	defer RegisterExtensionParsers("zeromod", ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.Mod = -8
+	state.BasicInfoExtension = []byte{1}
+	s := startFakeServer(t, state)
+
+	if _, err := s.GetBasicInfo(); err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("expected error about the panic, got %v", err)
+	}
+}
+
+func TestTeamScoresWithoutParser(t *testing.T) {
+	RegisterExtensionParsers("zeromod", ExtensionParsers{BasicInfo: parseTestExtension})
+	defer RegisterExtensionParsers("zeromod", ExtensionParsers{})
+
+	// without a parser knowing the mod's format, appended data can't be told apart from teams
+	state := fakeserver.DefaultState()
+	state.Mod = -8
+	state.Teams = state.Teams[:1]
+	state.TeamScoresExtension = []byte{'x', 0, 5, 0xff}
+	s := startFakeServer(t, state)
+
+	teamScores, err := s.GetTeamScores()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teamScores.Extension != nil || len(teamScores.Scores) != 2 || teamScores.Scores["x"].Score != 5 {
+		t.Errorf("expected appended data to be read as a team, got %+v", teamScores)
+	}
+}
+
+func TestModChange(t *testing.T) {
+	RegisterExtensionParsers("zeromod", ExtensionParsers{BasicInfo: parseTestExtension})
+	defer RegisterExtensionParsers("zeromod", ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.Mod = -8
+	state.BasicInfoExtension = []byte{1}
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basicInfo, err := s.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basicInfo.Extension == nil {
+		t.Fatal("expected zeromod extension")
+	}
+
+	// restarted running a different mod
+	state.Mod = -4
+	state.Uptime = 10
+	fake.SetState(state)
+
+	if _, err := s.GetUptime(); err != nil {
+		t.Fatal(err)
+	}
+	basicInfo, err = s.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basicInfo.Extension != nil {
+		t.Errorf("expected extension of the previous mod not to be parsed anymore, got %#v", basicInfo.Extension)
+	}
+}
@@ -2,7 +2,10 @@
 package extinfo
 
 import (
+	"errors"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -33,22 +36,67 @@ const (
 	MaxPacketLength = 512 // better to be safe
 )
 
+// Limits on what a response may contain, so broken or malicious servers can't make us allocate huge amounts of memory. Responses exceeding them are rejected with an error.
+const (
+	MaxClients      = 256        // CNs in a client info response; servers allow 128 players, plus bots
+	MaxTeams        = MaxClients // teams in a team scores response; in some modes, every player can be on a team of their own
+	MaxBases        = 128        // bases of one team in capture modes
+	MaxStringLength = 260        // characters in a string, e.g. a name or the server description; the game's own limit is 260 as well (MAXSTRLEN)
+)
+
+// ErrNotTeamMode is returned when querying team scores while the server is not running a team mode.
+var ErrNotTeamMode = errors.New("extinfo: server is not running a team mode")
+
+// UnknownClientError is returned when querying information about a client the server does not know, e.g. because it disconnected.
+type UnknownClientError struct {
+	ClientNum int
+}
+
+func (err UnknownClientError) Error() string {
+	return "extinfo: no client with cn " + strconv.Itoa(err.ClientNum)
+}
+
+// largest datagram a response can consist of; vanilla servers never send more than MaxPacketLength bytes, but some mods do
+const maxDatagramLength = 65507
+
 // Server represents a Sauerbraten game server.
 type Server struct {
-	addr    *net.UDPAddr
-	timeOut time.Duration
+	addr      *net.UDPAddr
+	timeOut   time.Duration
+	deadline  time.Time // zero if there is none
+	transport Transport
+
+	// the server's mod, detected when an extension has to be parsed; a server can be restarted running a different mod, so it is detected again after modTTL, or when the server's uptime went backwards
+	modMutex    sync.Mutex
+	mod         string
+	modDetected time.Time // zero if the mod has to be detected (again)
+	uptime      int       // the last uptime the server reported
 }
 
 // NewServer returns a Server to query information from.
-func NewServer(addr net.UDPAddr, timeOut time.Duration) (*Server, error) {
+func NewServer(addr net.UDPAddr, timeOut time.Duration, options ...ServerOption) (*Server, error) {
 	addr.Port++
 	_addr, err := net.ResolveUDPAddr("udp", addr.String())
 	if err != nil {
 		return nil, err
 	}
 
-	return &Server{
-		addr:    _addr,
-		timeOut: timeOut,
-	}, nil
+	s := &Server{
+		addr:      _addr,
+		timeOut:   timeOut,
+		transport: DefaultTransport,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// Addr returns the address of the server, i.e. the one players connect to (which is one port below the one info is queried from).
+func (s *Server) Addr() net.UDPAddr {
+	addr := *s.addr
+	addr.Port--
+	return addr
 }
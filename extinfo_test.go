@@ -2,19 +2,20 @@ package extinfo
 
 import (
 	"log"
-	"net"
 	"testing"
 	"time"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
 )
 
 var srv *Server
 
 func init() {
-	addr, err := net.ResolveUDPAddr("udp", "localhost:28785")
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
 	if err != nil {
 		panic(err)
 	}
-	srv, err = NewServer(*addr, 5*time.Second)
+	srv, err = NewServer(fake.Addr(), 5*time.Second)
 	if err != nil {
 		panic(err)
 	}
@@ -52,6 +53,21 @@ func TestGetAllClientInfo(t *testing.T) {
 	}
 }
 
+func TestGetAllClientInfoRaw(t *testing.T) {
+	allClientInfoRaw, err := srv.GetAllClientInfoRaw(IncludeBots(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cn, clientInfoRaw := range allClientInfoRaw {
+		if cn > MaxPlayerCN || clientInfoRaw.ClientNum != cn {
+			t.Errorf("unexpected client %d: %+v", cn, clientInfoRaw)
+		}
+	}
+	if len(allClientInfoRaw) == 0 {
+		t.Error("expected clients")
+	}
+}
+
 func TestGetTeamScores(t *testing.T) {
 	_, err := srv.GetTeamScores()
 	if err != nil {
@@ -59,3 +75,29 @@ func TestGetTeamScores(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestQueryExtended(t *testing.T) {
+	response, err := srv.QueryExtended(fakeserver.CustomCommand, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []int{1, 2} {
+		arg, err := response.ReadInt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if arg != expected {
+			t.Errorf("expected %d, got %d", expected, arg)
+		}
+	}
+
+	if response.HasRemaining() {
+		t.Errorf("expected end of response, %d bytes left", response.Len())
+	}
+
+	_, err = srv.QueryExtended(0x7E)
+	if err == nil {
+		t.Error("expected error for unknown command")
+	}
+}
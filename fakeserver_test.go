@@ -0,0 +1,26 @@
+package extinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+// startFakeServer starts a fake server reporting state and returns a Server querying it.
+func startFakeServer(tb testing.TB, state fakeserver.State, options ...ServerOption) *Server {
+	tb.Helper()
+
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { fake.Close() })
+
+	s, err := NewServer(fake.Addr(), time.Second, options...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return s
+}
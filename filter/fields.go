@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// field is a property of a server that terms can compare and servers can be sorted by. Exactly one of number and text is set.
+type field struct {
+	number func(snap *poll.Snapshot) int
+	text   func(snap *poll.Snapshot) []string // a term matches if it matches any of the values
+}
+
+func numberField(f func(snap *poll.Snapshot) int) field {
+	return field{number: f}
+}
+
+func textField(f func(snap *poll.Snapshot) string) field {
+	return field{text: func(snap *poll.Snapshot) []string { return []string{f(snap)} }}
+}
+
+// fields by name
+var fields = map[string]field{
+	"addr":        textField(func(snap *poll.Snapshot) string { return snap.Addr }),
+	"mode":        textField(func(snap *poll.Snapshot) string { return snap.BasicInfo.GameMode }),
+	"map":         textField(func(snap *poll.Snapshot) string { return snap.BasicInfo.Map }),
+	"mastermode":  textField(func(snap *poll.Snapshot) string { return snap.BasicInfo.MasterMode }),
+	"description": textField(func(snap *poll.Snapshot) string { return snap.Description() }),
+	"mod":         textField(func(snap *poll.Snapshot) string { return snap.Mod }),
+	"player":      {text: playerNames},
+
+	"players": numberField(func(snap *poll.Snapshot) int {
+		counts := extinfo.CountClients(snap.Clients)
+		return counts.Humans - counts.Spectators
+	}),
+	"spectators": numberField(func(snap *poll.Snapshot) int { return extinfo.CountClients(snap.Clients).Spectators }),
+	"bots":       numberField(func(snap *poll.Snapshot) int { return extinfo.CountClients(snap.Clients).Bots }),
+	"clients":    numberField(func(snap *poll.Snapshot) int { return snap.BasicInfo.NumberOfClients }),
+	"maxclients": numberField(func(snap *poll.Snapshot) int { return snap.BasicInfo.MaxNumberOfClients }),
+	"slots":      numberField(freeSlots),
+	"timeleft":   numberField(func(snap *poll.Snapshot) int { return snap.BasicInfo.SecsLeft }),
+	"ping":       numberField(func(snap *poll.Snapshot) int { return snap.Ping }),
+	"uptime":     numberField(func(snap *poll.Snapshot) int { return snap.Uptime }),
+	"gamespeed":  numberField(func(snap *poll.Snapshot) int { return snap.BasicInfo.GameSpeed }),
+	"protocol":   numberField(func(snap *poll.Snapshot) int { return snap.BasicInfo.ProtocolVersion }),
+}
+
+// names of the human clients
+func playerNames(snap *poll.Snapshot) []string {
+	names := make([]string, 0, len(snap.Clients))
+	for _, clientInfo := range snap.Clients {
+		if !clientInfo.IsBot {
+			names = append(names, clientInfo.Name)
+		}
+	}
+	return names
+}
+
+// free client slots; can't be negative, although admins can join full servers
+func freeSlots(snap *poll.Snapshot) int {
+	if free := snap.BasicInfo.MaxNumberOfClients - snap.BasicInfo.NumberOfClients; free > 0 {
+		return free
+	}
+	return 0
+}
+
+// flags are terms without operator and value
+var flags = map[string]func(snap *poll.Snapshot) bool{
+	"full":     func(snap *poll.Snapshot) bool { return freeSlots(snap) == 0 },
+	"empty":    func(snap *poll.Snapshot) bool { return snap.BasicInfo.NumberOfClients == 0 },
+	"paused":   func(snap *poll.Snapshot) bool { return snap.BasicInfo.Paused },
+	"teammode": func(snap *poll.Snapshot) bool { return extinfo.IsTeamMode(snap.BasicInfo.GameMode) },
+}
+
+// flags about whether the server responds, which also apply to servers that never did
+var statusFlags = map[string]func(state poll.State) bool{
+	"online":  online,
+	"offline": func(state poll.State) bool { return !online(state) },
+}
+
+// reports whether the last poll of the server succeeded
+func online(state poll.State) bool {
+	return state.Snapshot != nil && state.LastError == ""
+}
@@ -0,0 +1,337 @@
+// Package filter selects and sorts servers using short expressions, so server browsers, bots, the command-line tool and the HTTP API share one syntax:
+//
+//	mode:"insta ctf" players>=4 mastermode:open !full map~"^ot"
+//
+// A filter is a list of terms separated by spaces, all of which have to match. A term is one of
+//
+//	field:value[,value...]   the field equals one of the values (text is compared case-insensitively)
+//	field~regexp             the field matches the regular expression (see package regexp; use (?i) to ignore case)
+//	field<n, <=, >, >=, =    compares a number field
+//	flag                     full, empty, paused, teammode, online or offline
+//
+// and can be negated by prefixing it with !. Values containing spaces or commas are quoted using double quotes; \" and \\ are the only escape sequences.
+//
+// Text fields are addr, mode, map, mastermode, description, mod and player (matches if any player's name matches).
+// Number fields are players (not spectating, without bots), spectators, bots, clients (as reported by the server, bots excluded), maxclients, slots (free), timeleft (seconds), ping (milliseconds), uptime (seconds), gamespeed and protocol.
+//
+// Terms are evaluated against the most recent successful poll of a server, even if later polls failed; add online to exclude those servers. Servers that never responded only match online and offline terms.
+//
+// Orders are comma-separated lists of fields to sort by, e.g. "-players,ping": a leading - sorts in descending order. See ParseOrder().
+package filter
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// Filter selects servers. A nil or empty Filter matches all servers.
+type Filter struct {
+	expr  string
+	terms []term
+}
+
+type term struct {
+	negate bool
+
+	status func(state poll.State) bool    // set for online and offline
+	flag   func(snap *poll.Snapshot) bool // set for other flags
+	field  field                          // set for comparisons
+
+	op     string   // ":", "~", "=", "<", "<=", ">" or ">="
+	values []string // for ":" on text fields, lower-cased
+	re     *regexp.Regexp
+	n      []int // for comparisons of number fields, multiple only with ":"
+}
+
+// Parse parses a filter expression.
+func Parse(expr string) (*Filter, error) {
+	f := &Filter{expr: expr}
+	sc := &scanner{s: expr}
+
+	for {
+		sc.skipSpace()
+		if sc.done() {
+			return f, nil
+		}
+
+		t, err := sc.term()
+		if err != nil {
+			return nil, errors.New("filter: " + err.Error())
+		}
+		f.terms = append(f.terms, t)
+	}
+}
+
+// String returns the expression f was parsed from.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// Match reports whether state matches all terms of f.
+func (f *Filter) Match(state poll.State) bool {
+	if f == nil {
+		return true
+	}
+	for _, t := range f.terms {
+		if !t.match(state) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t term) match(state poll.State) bool {
+	if t.status != nil {
+		return t.status(state) != t.negate
+	}
+
+	snap := state.Snapshot
+	if snap == nil {
+		return false
+	}
+
+	if t.flag != nil {
+		return t.flag(snap) != t.negate
+	}
+
+	if t.field.number != nil {
+		return t.compare(t.field.number(snap)) != t.negate
+	}
+
+	for _, value := range t.field.text(snap) {
+		if t.matchText(value) {
+			return !t.negate
+		}
+	}
+	return t.negate
+}
+
+func (t term) compare(n int) bool {
+	switch t.op {
+	case "<":
+		return n < t.n[0]
+	case "<=":
+		return n <= t.n[0]
+	case ">":
+		return n > t.n[0]
+	case ">=":
+		return n >= t.n[0]
+	default:
+		for _, m := range t.n {
+			if n == m {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (t term) matchText(s string) bool {
+	if t.re != nil {
+		return t.re.MatchString(s)
+	}
+	s = strings.ToLower(s)
+	for _, value := range t.values {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the states matching f, sorted by o. Both f and o may be nil, to select all states or keep their order.
+func Select(states []poll.State, f *Filter, o *Order) []poll.State {
+	selected := make([]poll.State, 0, len(states))
+	for _, state := range states {
+		if f.Match(state) {
+			selected = append(selected, state)
+		}
+	}
+	o.Sort(selected)
+	return selected
+}
+
+// scanner splits an expression into terms
+type scanner struct {
+	s   string
+	pos int
+}
+
+func (sc *scanner) done() bool {
+	return sc.pos >= len(sc.s)
+}
+
+func (sc *scanner) peek() byte {
+	if sc.done() {
+		return 0
+	}
+	return sc.s[sc.pos]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (sc *scanner) skipSpace() {
+	for !sc.done() && isSpace(sc.peek()) {
+		sc.pos++
+	}
+}
+
+// reads [a-z]+, ignoring case
+func (sc *scanner) name() string {
+	start := sc.pos
+	for c := sc.peek(); ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z'); c = sc.peek() {
+		sc.pos++
+	}
+	return strings.ToLower(sc.s[start:sc.pos])
+}
+
+// reads an operator, if there is one
+func (sc *scanner) op() string {
+	for _, op := range []string{"<=", ">=", ":", "~", "=", "<", ">"} {
+		if strings.HasPrefix(sc.s[sc.pos:], op) {
+			sc.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// reads a quoted or bare value; bare values end at white space, or, if list is true, at commas
+func (sc *scanner) value(list bool) (string, error) {
+	if sc.peek() != '"' {
+		start := sc.pos
+		for !sc.done() && !isSpace(sc.peek()) && !(list && sc.peek() == ',') {
+			sc.pos++
+		}
+		return sc.s[start:sc.pos], nil
+	}
+
+	start := sc.pos
+	sc.pos++ // opening quote
+	value := strings.Builder{}
+	for {
+		if sc.done() {
+			return "", errors.New("unterminated string starting at position " + strconv.Itoa(start+1))
+		}
+		c := sc.s[sc.pos]
+		sc.pos++
+		switch {
+		case c == '"':
+			return value.String(), nil
+		case c == '\\' && (sc.peek() == '"' || sc.peek() == '\\'):
+			value.WriteByte(sc.s[sc.pos])
+			sc.pos++
+		default:
+			value.WriteByte(c)
+		}
+	}
+}
+
+// reads values separated by commas
+func (sc *scanner) values() ([]string, error) {
+	values := []string{}
+	for {
+		value, err := sc.value(true)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if sc.peek() != ',' {
+			return values, nil
+		}
+		sc.pos++
+	}
+}
+
+func (sc *scanner) term() (t term, err error) {
+	start := sc.pos
+	if sc.peek() == '!' {
+		t.negate = true
+		sc.pos++
+	}
+
+	name := sc.name()
+	if name == "" {
+		return t, errors.New("expected field or flag at position " + strconv.Itoa(sc.pos+1))
+	}
+
+	t.op = sc.op()
+	if t.op == "" {
+		if !sc.done() && !isSpace(sc.peek()) {
+			return t, errors.New("unexpected " + strconv.Quote(string(sc.peek())) + " at position " + strconv.Itoa(sc.pos+1))
+		}
+		if status, ok := statusFlags[name]; ok {
+			t.status = status
+		} else if flag, ok := flags[name]; ok {
+			t.flag = flag
+		} else {
+			return t, errors.New("unknown flag " + strconv.Quote(name))
+		}
+		return t, nil
+	}
+
+	var ok bool
+	t.field, ok = fields[name]
+	if !ok {
+		return t, errors.New("unknown field " + strconv.Quote(name))
+	}
+
+	var values []string
+	switch t.op {
+	case ":":
+		values, err = sc.values()
+	default:
+		var value string
+		value, err = sc.value(false)
+		values = []string{value}
+	}
+	if err != nil {
+		return
+	}
+	if !sc.done() && !isSpace(sc.peek()) {
+		return t, errors.New("unexpected " + strconv.Quote(string(sc.peek())) + " at position " + strconv.Itoa(sc.pos+1))
+	}
+	for _, value := range values {
+		if value == "" {
+			return t, errors.New("value missing in " + strconv.Quote(sc.s[start:sc.pos]))
+		}
+	}
+
+	if t.field.text != nil {
+		switch t.op {
+		case ":":
+			for _, value := range values {
+				t.values = append(t.values, strings.ToLower(value))
+			}
+		case "~":
+			t.re, err = regexp.Compile(values[0])
+			if err != nil {
+				return t, errors.New("invalid regular expression in " + strconv.Quote(sc.s[start:sc.pos]) + ": " + err.Error())
+			}
+		default:
+			return t, errors.New(name + " is text and can't be compared using " + t.op)
+		}
+		return t, nil
+	}
+
+	if t.op == "~" {
+		return t, errors.New(name + " is a number and can't be matched against a regular expression")
+	}
+	for _, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return t, errors.New(name + " is a number, not " + strconv.Quote(value))
+		}
+		t.n = append(t.n, n)
+	}
+	return t, nil
+}
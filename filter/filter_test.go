@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+func client(cn int, name, state string) extinfo.ClientInfo {
+	clientInfo := extinfo.ClientInfo{State: state, IsBot: cn > extinfo.MaxPlayerCN}
+	clientInfo.ClientNum = cn
+	clientInfo.Name = name
+	return clientInfo
+}
+
+// returns the state of a server that responded with the given properties
+func server(addr, mode, mapName, masterMode string, maxClients, ping int, clients ...extinfo.ClientInfo) poll.State {
+	snap := &poll.Snapshot{Addr: addr, Ping: ping, Clients: map[int]extinfo.ClientInfo{}}
+	snap.BasicInfo.GameMode = mode
+	snap.BasicInfo.Map = mapName
+	snap.BasicInfo.MasterMode = masterMode
+	snap.BasicInfo.MaxNumberOfClients = maxClients
+	snap.BasicInfo.SecsLeft = 300
+	for _, clientInfo := range clients {
+		snap.Clients[clientInfo.ClientNum] = clientInfo
+		if !clientInfo.IsBot {
+			snap.BasicInfo.NumberOfClients++
+		}
+	}
+	return poll.State{Addr: addr, Snapshot: snap}
+}
+
+var states = []poll.State{
+	server("a:1", "insta ctf", "ot", "open", 16, 40,
+		client(0, "alice", "alive"), client(1, "bob", "dead"), client(2, "carol", "alive"), client(3, "dave", "alive"), client(4, "eve", "spectator")),
+	server("b:1", "insta ctf", "otsun", "open", 4, 20,
+		client(0, "frank", "alive"), client(1, "grace", "alive"), client(2, "heidi", "alive"), client(3, "ivan", "alive")),
+	server("c:1", "efficiency ctf", "reissen", "locked", 16, 80,
+		client(0, "judy", "alive"), client(1, "[TBMC]mallory", "alive"), client(128, "bot", "alive")),
+	server("d:1", "insta ctf", "forge", "open", 16, 10,
+		client(0, "niaj", "alive"), client(1, "olivia", "alive"), client(2, "peggy", "alive"), client(3, "rupert", "alive")),
+	{Addr: "e:1", LastError: "i/o timeout"},
+	func() poll.State {
+		state := server("f:1", "ffa", "ot", "open", 16, 5)
+		state.LastError = "i/o timeout"
+		return state
+	}(),
+}
+
+// returns the addresses of the selected states
+func addrs(states []poll.State) []string {
+	a := []string{}
+	for _, state := range states {
+		a = append(a, state.Addr)
+	}
+	return a
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{``, []string{"a:1", "b:1", "c:1", "d:1", "e:1", "f:1"}},
+		{`mode:"insta ctf" players>=4 mastermode:open !full map~"^ot"`, []string{"a:1"}},
+		{`mode:"Insta CTF","efficiency ctf"`, []string{"a:1", "b:1", "c:1", "d:1"}},
+		{`MODE:ffa`, []string{"f:1"}},
+		{`players=4`, []string{"a:1", "b:1", "d:1"}},
+		{`players:2,4 spectators<1`, []string{"b:1", "c:1", "d:1"}},
+		{`bots>0`, []string{"c:1"}},
+		{`clients>4`, []string{"a:1"}},
+		{`slots>=12 slots<=14`, []string{"c:1", "d:1"}},
+		{`full`, []string{"b:1"}},
+		{`empty`, []string{"f:1"}},
+		{`!empty`, []string{"a:1", "b:1", "c:1", "d:1"}},
+		{`online`, []string{"a:1", "b:1", "c:1", "d:1"}},
+		{`offline`, []string{"e:1", "f:1"}},
+		{`!online`, []string{"e:1", "f:1"}},
+		{`map~^ot online`, []string{"a:1", "b:1"}},
+		{`!map~^ot`, []string{"c:1", "d:1"}},
+		{`player:Alice`, []string{"a:1"}},
+		{`player~"^\[TBMC\]"`, []string{"c:1"}},
+		{`player:bot`, []string{}},
+		{`ping<30 timeleft>=300`, []string{"b:1", "d:1", "f:1"}},
+		{`description:"a \"quoted\" description"`, []string{}},
+		{"\tteammode   !paused ", []string{"a:1", "b:1", "c:1", "d:1"}},
+	}
+
+	for _, test := range tests {
+		f, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if selected := addrs(Select(states, f, nil)); !reflect.DeepEqual(selected, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.expr, test.expected, selected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr  string
+		error string
+	}{
+		{`crowded`, `unknown flag "crowded"`},
+		{`size>4`, `unknown field "size"`},
+		{`players>four`, `players is a number, not "four"`},
+		{`players~4`, `can't be matched against a regular expression`},
+		{`map>ot`, `map is text and can't be compared using >`},
+		{`map:`, `value missing in "map:"`},
+		{`mode:"insta ctf`, `unterminated string starting at position 6`},
+		{`mode:"insta"ctf`, `unexpected "c" at position 13`},
+		{`map~"("`, `invalid regular expression`},
+		{`!`, `expected field or flag at position 2`},
+		{`full!`, `unexpected "!" at position 5`},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.expr)
+		if err == nil || !strings.HasPrefix(err.Error(), "filter: ") || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: expected error containing %q, got %v", test.expr, test.error, err)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{`-players,ping`, []string{"d:1", "b:1", "a:1", "c:1", "f:1", "e:1"}},
+		{`ping`, []string{"f:1", "d:1", "b:1", "a:1", "c:1", "e:1"}},
+		{`mastermode, -map`, []string{"c:1", "b:1", "a:1", "f:1", "d:1", "e:1"}},
+	}
+
+	for _, test := range tests {
+		o, err := ParseOrder(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if sorted := addrs(Select(states, nil, o)); !reflect.DeepEqual(sorted, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.expr, test.expected, sorted)
+		}
+	}
+
+	for _, expr := range []string{"", "player", "players,,ping", "-size"} {
+		if _, err := ParseOrder(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestNil(t *testing.T) {
+	var f *Filter
+	var o *Order
+	if !f.Match(states[0]) || f.String() != "" || o.String() != "" {
+		t.Error("nil filter should match everything")
+	}
+	if selected := Select(states, nil, nil); len(selected) != len(states) {
+		t.Errorf("expected all states, got %v", addrs(selected))
+	}
+}
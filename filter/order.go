@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// Order sorts servers. A nil Order keeps the order servers are in.
+type Order struct {
+	expr string
+	keys []sortKey
+}
+
+type sortKey struct {
+	field      field
+	descending bool
+}
+
+// ParseOrder parses a comma-separated list of fields to sort by, e.g. "-players,ping", which sorts by players in descending order, and by ping (ascending) among servers with the same number of players. Any field but player can be used.
+func ParseOrder(expr string) (*Order, error) {
+	o := &Order{expr: expr}
+
+	for _, name := range strings.Split(expr, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		key := sortKey{}
+		if strings.HasPrefix(name, "-") {
+			key.descending = true
+			name = name[1:]
+		}
+
+		var ok bool
+		key.field, ok = fields[name]
+		if !ok || name == "player" {
+			return nil, errors.New("filter: can't sort by " + strconv.Quote(name))
+		}
+
+		o.keys = append(o.keys, key)
+	}
+
+	return o, nil
+}
+
+// String returns the expression o was parsed from.
+func (o *Order) String() string {
+	if o == nil {
+		return ""
+	}
+	return o.expr
+}
+
+// Sort sorts states in place. Servers that never responded come last; servers that are equal in all fields of o are sorted by address.
+func (o *Order) Sort(states []poll.State) {
+	if o == nil {
+		return
+	}
+
+	sort.SliceStable(states, func(i, j int) bool {
+		a, b := states[i].Snapshot, states[j].Snapshot
+		if a == nil || b == nil {
+			if a != b {
+				return b == nil
+			}
+			return states[i].Addr < states[j].Addr
+		}
+
+		for _, key := range o.keys {
+			if c := key.compare(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return states[i].Addr < states[j].Addr
+	})
+}
+
+// compares the field of a and b, returning -1 if a comes first, 1 if b comes first and 0 if they are equal
+func (key sortKey) compare(a, b *poll.Snapshot) (c int) {
+	if key.field.number != nil {
+		x, y := key.field.number(a), key.field.number(b)
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(strings.ToLower(key.field.text(a)[0]), strings.ToLower(key.field.text(b)[0]))
+	}
+
+	if key.descending {
+		return -c
+	}
+	return c
+}
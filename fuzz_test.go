@@ -0,0 +1,204 @@
+package extinfo
+
+import (
+	"net"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sauerbraten/cubecode"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+// states of the fake server whose responses seed the fuzzers
+func seedStates() []fakeserver.State {
+	vanilla := fakeserver.DefaultState()
+
+	old := fakeserver.DefaultState()
+	old.FiveAttributes = true
+
+	capture := fakeserver.DefaultState()
+	capture.GameMode = 9
+	capture.Teams = []fakeserver.Team{
+		{Name: "good", Score: 120, Bases: []int{0, 2, 3}},
+		{Name: "evil", Score: 80, Bases: []int{1}},
+	}
+
+	mod := fakeserver.DefaultState()
+	mod.Mod = -8
+	mod.BasicInfoExtension = []byte{1, 2}
+	mod.ClientInfoExtension = []byte{3}
+	mod.TeamScoresExtension = []byte{4}
+
+	return []fakeserver.State{vanilla, old, capture, mod}
+}
+
+// adds the datagrams the seed states respond to request with to f, with everything before the part parsed by the fuzzed function cut off
+func addSeeds(f *testing.F, request []byte) {
+	for _, state := range seedStates() {
+		for _, datagram := range state.Respond(request) {
+			if request[0] == InfoTypeBasic {
+				f.Add(datagram[len(request):])
+				continue
+			}
+
+			response, _, err := parseExtendedResponseHeader(request, datagram)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(packetBytes(response))
+		}
+	}
+}
+
+// a server whose mod is known already, so parsing extensions doesn't send queries
+func offlineServer() *Server {
+	return &Server{modDetected: time.Now()}
+}
+
+func checkString(t *testing.T, s string) {
+	if utf8.RuneCountInString(s) > MaxStringLength {
+		t.Errorf("string of %d characters accepted", utf8.RuneCountInString(s))
+	}
+}
+
+func FuzzParseExtendedResponseHeader(f *testing.F) {
+	state := fakeserver.DefaultState()
+	for _, request := range [][]byte{
+		buildExtendedRequest(ExtInfoTypeUptime),
+		buildExtendedRequest(ExtInfoTypeClientInfo, -1),
+		buildExtendedRequest(ExtInfoTypeTeamScores),
+	} {
+		for _, datagram := range state.Respond(request) {
+			f.Add(request[1], datagram)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, command byte, rawResponse []byte) {
+		parseExtendedResponseHeader(buildExtendedRequest(command), rawResponse)
+	})
+}
+
+func FuzzParseBasicInfo(f *testing.F) {
+	addSeeds(f, buildRequest(InfoTypeBasic, 0, 0))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		basicInfoRaw, err := offlineServer().parseBasicInfo(cubecode.NewPacket(body))
+		if err != nil {
+			return
+		}
+		checkString(t, basicInfoRaw.Map)
+		checkString(t, basicInfoRaw.Description)
+	})
+}
+
+func FuzzParseTeamScores(f *testing.F) {
+	addSeeds(f, buildRequest(InfoTypeExtended, ExtInfoTypeTeamScores, 0))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		teamScoresRaw, err := offlineServer().parseTeamScores(cubecode.NewPacket(body))
+		if err != nil {
+			return
+		}
+		if len(teamScoresRaw.Scores) > MaxTeams {
+			t.Errorf("%d teams accepted", len(teamScoresRaw.Scores))
+		}
+		for name, score := range teamScoresRaw.Scores {
+			checkString(t, name)
+			if len(score.Bases) > MaxBases {
+				t.Errorf("%d bases accepted", len(score.Bases))
+			}
+		}
+	})
+}
+
+func FuzzParseClientNums(f *testing.F) {
+	addSeeds(f, buildRequest(InfoTypeExtended, ExtInfoTypeClientInfo, -1))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		packet := cubecode.NewPacket(body)
+		if header, err := packet.ReadByte(); err != nil || header != ClientInfoResponseTypeCNs {
+			return
+		}
+
+		clientNums, err := parseClientNums(packet)
+		if err != nil {
+			return
+		}
+		if len(clientNums) > MaxClients {
+			t.Errorf("%d clients accepted", len(clientNums))
+		}
+	})
+}
+
+func FuzzParseClientInfo(f *testing.F) {
+	addSeeds(f, buildRequest(InfoTypeExtended, ExtInfoTypeClientInfo, -1))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		packet := cubecode.NewPacket(body)
+		if header, err := packet.ReadByte(); err != nil || header != ClientInfoResponseTypeInfo {
+			return
+		}
+
+		clientInfoRaw, err := offlineServer().parseClientInfo(packet, ClientInfoRaw{})
+		if err != nil {
+			return
+		}
+		checkString(t, clientInfoRaw.Name)
+		checkString(t, clientInfoRaw.Team)
+	})
+}
+
+func FuzzParseServerMod(f *testing.F) {
+	addSeeds(f, buildExtendedRequest(ExtInfoTypeUptime, 1))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		parseServerMod(cubecode.NewPacket(body))
+	})
+}
+
+// fuzzes the exchange of datagrams when querying all clients, with the list of CNs and three datagrams following it
+func FuzzGetAllClientInfo(f *testing.F) {
+	request := buildExtendedRequest(ExtInfoTypeClientInfo, -1)
+
+	for _, state := range seedStates() {
+		state.Clients = state.Clients[:3]
+		datagrams := state.Respond(request)
+		f.Add(datagrams[0], datagrams[1], datagrams[2], datagrams[3])
+		f.Add(datagrams[0], datagrams[3], datagrams[1], datagrams[1])
+	}
+
+	addr := net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28785}
+	info := addr
+	info.Port++
+
+	f.Fuzz(func(t *testing.T, clientNums, first, second, third []byte) {
+		replay := &Replay{
+			exchanges: map[string][]Exchange{
+				replayKey(&info, request): {{
+					Responses: []Datagram{{Data: clientNums}, {Data: first}, {Data: second}, {Data: third}},
+				}},
+			},
+			next: map[string]int{},
+		}
+
+		s, err := NewServer(addr, 0, UseTransport(replay))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.modDetected = time.Now()
+
+		allClientInfo, err := s.GetAllClientInfo()
+		if err != nil {
+			return
+		}
+		if len(allClientInfo) > 3 {
+			t.Errorf("%d clients from 3 datagrams", len(allClientInfo))
+		}
+		for cn, clientInfo := range allClientInfo {
+			if clientInfo.ClientNum != cn {
+				t.Errorf("client info of cn %d stored as cn %d", clientInfo.ClientNum, cn)
+			}
+		}
+	})
+}
@@ -0,0 +1,366 @@
+// Package fakeserver implements a UDP server answering extinfo queries the way a Sauerbraten game server does, for use in tests.
+package fakeserver
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/cubecode"
+)
+
+// protocol constants, duplicated here so tests of package extinfo can use this package
+const (
+	infoTypeExtended = 0x00
+
+	extInfoACK     = 0xFF
+	extInfoVersion = 105
+	extInfoError   = 0x01
+
+	extInfoTypeUptime     = 0x00
+	extInfoTypeClientInfo = 0x01
+	extInfoTypeTeamScores = 0x02
+
+	clientInfoResponseTypeCNs  = 0xF6
+	clientInfoResponseTypeInfo = 0xF5
+
+	// CustomCommand is an extended info command only the fake server knows; it responds with its arguments.
+	CustomCommand = 0x7F
+)
+
+// Client is a client connected to the fake server.
+type Client struct {
+	CN        int
+	Ping      int
+	Name      string
+	Team      string
+	Frags     int
+	Flags     int
+	Deaths    int
+	Teamkills int
+	Accuracy  int
+	Health    int
+	Armour    int
+	Weapon    int
+	Privilege int
+	State     int
+	IP        net.IP
+}
+
+// Team is a team's score in the fake server's current game.
+type Team struct {
+	Name  string
+	Score int
+	Bases []int // nil outside of capture modes
+}
+
+// State is the game state a fake server reports.
+type State struct {
+	Uptime         int
+	Mod            int // 0 for vanilla servers, which don't identify themselves
+	FiveAttributes bool
+
+	NumberOfClients int
+	ProtocolVersion int
+	GameMode        int
+	SecsLeft        int
+	MaxClients      int
+	MasterMode      int
+	Paused          bool
+	GameSpeed       int
+	Map             string
+	Description     string
+
+	TeamMode bool
+	Teams    []Team
+	Clients  []Client
+
+	// send the client info datagrams in reverse order of the CN list
+	ReverseClientInfo bool
+	// send the datagram listing the CNs after the client info datagrams, as UDP may deliver it
+	CNsLast bool
+	// time to wait before responding to a request
+	Delay time.Duration
+
+	// raw bytes appended to the respective responses, as some mods do
+	BasicInfoExtension  []byte
+	ClientInfoExtension []byte
+	TeamScoresExtension []byte
+}
+
+// DefaultState returns the state of a server in the middle of an insta ctf game, with three humans (one of them spectating) and one bot.
+func DefaultState() State {
+	return State{
+		Uptime:          3600,
+		NumberOfClients: 3,
+		ProtocolVersion: 259,
+		GameMode:        12,
+		SecsLeft:        300,
+		MaxClients:      16,
+		MasterMode:      0,
+		GameSpeed:       100,
+		Map:             "forge",
+		Description:     "\f3fake \f7server",
+		TeamMode:        true,
+		Teams: []Team{
+			{Name: "good", Score: 3},
+			{Name: "evil", Score: 1},
+		},
+		Clients: []Client{
+			{CN: 0, Ping: 24, Name: "alice", Team: "good", Frags: 20, Flags: 2, Deaths: 10, Accuracy: 45, Health: 100, Weapon: 4, State: 0, IP: net.IPv4(10, 0, 0, 0)},
+			{CN: 10, Ping: 80, Name: "bob", Team: "evil", Frags: 12, Flags: 1, Deaths: 14, Teamkills: 1, Accuracy: 38, Weapon: 4, Privilege: 1, State: 1, IP: net.IPv4(10, 0, 1, 0)},
+			{CN: 11, Ping: 40, Name: "carol", Team: "good", Frags: 0, Deaths: 0, Weapon: 4, State: 5, IP: net.IPv4(10, 0, 2, 0)},
+			{CN: 128, Ping: 0, Name: "bot", Team: "evil", Frags: 5, Deaths: 9, Accuracy: 20, Health: 60, Weapon: 4, State: 0, IP: net.IPv4(0, 0, 0, 0)},
+		},
+	}
+}
+
+// Server is a fake Sauerbraten server.
+type Server struct {
+	conn *net.UDPConn
+
+	mutex sync.Mutex
+	state State
+}
+
+// Start starts a fake server reporting state on a random local port.
+func Start(state State) (*Server, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{conn: conn, state: state}
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the server's game address, which is one port below the one it answers queries on.
+func (s *Server) Addr() net.UDPAddr {
+	addr := *s.conn.LocalAddr().(*net.UDPAddr)
+	addr.Port--
+	return addr
+}
+
+// SetState replaces the state the server reports.
+func (s *Server) SetState(state State) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state = state
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		responses := s.state.Respond(buf[:n])
+		delay := s.state.Delay
+		s.mutex.Unlock()
+
+		time.Sleep(delay)
+
+		for _, response := range responses {
+			s.conn.WriteToUDP(response, addr)
+		}
+	}
+}
+
+// Respond returns the datagrams a server in this state sends in reply to request.
+func (state *State) Respond(request []byte) [][]byte {
+	req := cubecode.NewPacket(append([]byte(nil), request...))
+
+	infoType, err := req.ReadInt()
+	if err != nil {
+		return nil
+	}
+
+	if infoType != infoTypeExtended {
+		return [][]byte{state.basicInfoResponse(request)}
+	}
+
+	command, err := req.ReadInt()
+	if err != nil {
+		return nil
+	}
+
+	switch command {
+	case extInfoTypeUptime:
+		p := newResponse(request)
+		p.WriteInt(int32(state.Uptime))
+		if modRequested, err := req.ReadInt(); err == nil && modRequested > 0 && state.Mod != 0 {
+			p.WriteInt(int32(state.Mod))
+		}
+		return [][]byte{packetBytes(p)}
+
+	case extInfoTypeClientInfo:
+		cn, err := req.ReadInt()
+		if err != nil {
+			return nil
+		}
+		return state.clientInfoResponses(request, cn)
+
+	case extInfoTypeTeamScores:
+		return [][]byte{state.teamScoresResponse(request)}
+
+	case CustomCommand:
+		p := newResponse(request)
+		p.WriteInt(0)
+		for req.HasRemaining() {
+			arg, _ := req.ReadInt()
+			p.WriteInt(int32(arg))
+		}
+		return [][]byte{packetBytes(p)}
+	}
+
+	p := newResponse(request)
+	p.WriteInt(extInfoError)
+	return [][]byte{packetBytes(p)}
+}
+
+func (state *State) basicInfoResponse(request []byte) []byte {
+	p := cubecode.NewPacket(append([]byte(nil), request...))
+	p.WriteInt(int32(state.NumberOfClients))
+	if state.FiveAttributes {
+		p.WriteInt(5)
+	} else {
+		p.WriteInt(7)
+	}
+	p.WriteInt(int32(state.ProtocolVersion))
+	p.WriteInt(int32(state.GameMode))
+	p.WriteInt(int32(state.SecsLeft))
+	p.WriteInt(int32(state.MaxClients))
+	p.WriteInt(int32(state.MasterMode))
+	if !state.FiveAttributes {
+		if state.Paused {
+			p.WriteInt(1)
+		} else {
+			p.WriteInt(0)
+		}
+		p.WriteInt(int32(state.GameSpeed))
+	}
+	p.WriteString(state.Map)
+	p.WriteString(state.Description)
+	writeBytes(p, state.BasicInfoExtension)
+
+	return packetBytes(p)
+}
+
+func (state *State) clientInfoResponses(request []byte, cn int) [][]byte {
+	clients := []Client{}
+	for _, c := range state.Clients {
+		if cn < 0 || c.CN == cn {
+			clients = append(clients, c)
+		}
+	}
+
+	p := newResponse(request)
+	if cn >= 0 && len(clients) == 0 {
+		p.WriteInt(extInfoError)
+		return [][]byte{packetBytes(p)}
+	}
+
+	p.WriteInt(0)
+	p.WriteByte(clientInfoResponseTypeCNs)
+	for _, c := range clients {
+		p.WriteInt(int32(c.CN))
+	}
+	responses := [][]byte{packetBytes(p)}
+
+	for _, c := range clients {
+		p := newResponse(request)
+		p.WriteInt(0)
+		p.WriteByte(clientInfoResponseTypeInfo)
+		p.WriteInt(int32(c.CN))
+		p.WriteInt(int32(c.Ping))
+		p.WriteString(c.Name)
+		p.WriteString(c.Team)
+		p.WriteInt(int32(c.Frags))
+		p.WriteInt(int32(c.Flags))
+		p.WriteInt(int32(c.Deaths))
+		p.WriteInt(int32(c.Teamkills))
+		p.WriteInt(int32(c.Accuracy))
+		p.WriteInt(int32(c.Health))
+		p.WriteInt(int32(c.Armour))
+		p.WriteInt(int32(c.Weapon))
+		p.WriteInt(int32(c.Privilege))
+		p.WriteInt(int32(c.State))
+		writeBytes(p, c.IP.To4()[:3])
+		writeBytes(p, state.ClientInfoExtension)
+		responses = append(responses, packetBytes(p))
+	}
+
+	if state.ReverseClientInfo {
+		for i, j := 1, len(responses)-1; i < j; i, j = i+1, j-1 {
+			responses[i], responses[j] = responses[j], responses[i]
+		}
+	}
+
+	if state.CNsLast {
+		responses = append(responses[1:], responses[0])
+	}
+
+	return responses
+}
+
+func (state *State) teamScoresResponse(request []byte) []byte {
+	p := newResponse(request)
+	if !state.TeamMode {
+		p.WriteInt(extInfoError)
+	} else {
+		p.WriteInt(0)
+	}
+	p.WriteInt(int32(state.GameMode))
+	p.WriteInt(int32(state.SecsLeft))
+
+	if state.TeamMode {
+		for _, team := range state.Teams {
+			p.WriteString(team.Name)
+			p.WriteInt(int32(team.Score))
+			if team.Bases == nil {
+				p.WriteInt(-1)
+			} else {
+				p.WriteInt(int32(len(team.Bases)))
+				for _, base := range team.Bases {
+					p.WriteInt(int32(base))
+				}
+			}
+		}
+		writeBytes(p, state.TeamScoresExtension)
+	}
+
+	return packetBytes(p)
+}
+
+// newResponse returns a packet starting with the replayed request, ACK and version, like every extended info response.
+func newResponse(request []byte) *cubecode.Packet {
+	p := cubecode.NewPacket(append([]byte(nil), request...))
+	p.WriteByte(extInfoACK)
+	p.WriteInt(extInfoVersion)
+	return p
+}
+
+func writeBytes(p *cubecode.Packet, b []byte) {
+	for _, c := range b {
+		p.WriteByte(c)
+	}
+}
+
+func packetBytes(p *cubecode.Packet) []byte {
+	b := []byte{}
+	for p.HasRemaining() {
+		c, _ := p.ReadByte()
+		b = append(b, c)
+	}
+	return b
+}
@@ -0,0 +1,55 @@
+package fakeserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Master is a fake master server listing a fixed set of game servers.
+type Master struct {
+	listener net.Listener
+	servers  []net.UDPAddr
+}
+
+// StartMaster starts a fake master server listing servers (game addresses, as returned by (*Server).Addr()) on a random local port.
+func StartMaster(servers ...net.UDPAddr) (*Master, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Master{listener: listener, servers: servers}
+	go m.serve()
+
+	return m, nil
+}
+
+// Addr returns the address the master server listens on, as host:port.
+func (m *Master) Addr() string {
+	return m.listener.Addr().String()
+}
+
+// Close stops the master server.
+func (m *Master) Close() error {
+	return m.listener.Close()
+}
+
+func (m *Master) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		// like the real master, answer "list" with one line per server and close the connection
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		if line == "list\n" {
+			for _, addr := range m.servers {
+				io.WriteString(conn, "addserver "+addr.IP.String()+" "+strconv.Itoa(addr.Port)+" 3 \"\" 0 0\n")
+			}
+		}
+		conn.Close()
+	}
+}
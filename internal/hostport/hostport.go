@@ -0,0 +1,16 @@
+// Package hostport resolves server addresses given on the command line or in configuration files.
+package hostport
+
+import "net"
+
+// DefaultPort is the port Sauerbraten servers listen on by default.
+const DefaultPort = "28785"
+
+// Resolve resolves a server address of the form host or host:port, using DefaultPort if no port is given.
+func Resolve(hostPort string) (*net.UDPAddr, error) {
+	if _, _, err := net.SplitHostPort(hostPort); err != nil {
+		hostPort = net.JoinHostPort(hostPort, DefaultPort)
+	}
+
+	return net.ResolveUDPAddr("udp", hostPort)
+}
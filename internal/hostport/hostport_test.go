@@ -0,0 +1,21 @@
+package hostport
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	addr, err := Resolve("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Port != 28785 {
+		t.Errorf("expected default port 28785, got %d", addr.Port)
+	}
+
+	addr, err = Resolve("127.0.0.1:10000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Port != 10000 {
+		t.Errorf("expected port 10000, got %d", addr.Port)
+	}
+}
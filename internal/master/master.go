@@ -0,0 +1,48 @@
+// Package master fetches the list of game servers registered with a Sauerbraten master server.
+package master
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port master servers listen on by default.
+const DefaultPort = "28787"
+
+// List asks the master server at master (host or host:port) for the addresses (host:port) of the game servers registered with it.
+func List(ctx context.Context, master string, timeout time.Duration) (addrs []string, err error) {
+	if _, _, err := net.SplitHostPort(master); err != nil {
+		master = net.JoinHostPort(master, DefaultPort)
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", master)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("list\n")); err != nil {
+		return nil, err
+	}
+
+	// the master answers with one "addserver <ip> <port> ..." line per server and closes the connection
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "addserver" {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(fields[1], fields[2]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("reading server list from " + master + ": " + err.Error())
+	}
+
+	return addrs, nil
+}
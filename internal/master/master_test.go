@@ -0,0 +1,30 @@
+package master
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func TestList(t *testing.T) {
+	m, err := fakeserver.StartMaster(
+		net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000},
+		net.UDPAddr{IP: net.IPv6loopback, Port: 20000},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	addrs, err := List(context.Background(), m.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"127.0.0.1:10000", "[::1]:20000"}; !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("expected %v, got %v", expected, addrs)
+	}
+}
@@ -59,6 +59,27 @@ func IsTeamMode(mode string) bool {
 	}
 }
 
+// IsFlagMode returns true when mode is a mode in which players score flags, i.e. a ctf, protect or hold mode, or skulls, i.e. a collect mode, false otherwise. The game ranks players by their score in these modes.
+func IsFlagMode(mode string) bool {
+	switch mode {
+	case "ctf",
+		"insta ctf",
+		"efficiency ctf",
+		"protect",
+		"insta protect",
+		"efficiency protect",
+		"hold",
+		"insta hold",
+		"efficiency hold",
+		"collect",
+		"insta collect",
+		"efficiency collect":
+		return true
+	default:
+		return false
+	}
+}
+
 // A slice containing the weapon names
 // The index of a weapon is equal to the int received in a response for client info, thus this slice maps the weapon ints to weapon strings
 var weaponNames = []string{"chain saw", "shotgun", "chain gun", "rocket launcher", "rifle", "grenade launcher", "pistol", "fire ball", "ice ball", "slime ball", "bite", "barrel"}
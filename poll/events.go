@@ -0,0 +1,137 @@
+package poll
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+)
+
+// EventType describes what happened on a server.
+type EventType string
+
+// Types of events
+const (
+	EventOffline      EventType = "offline"      // the server stopped responding; New is the error
+	EventOnline       EventType = "online"       // the server responds again
+	EventNewGame      EventType = "newgame"      // a new game started; Old and New are the previous and the new map
+	EventIntermission EventType = "intermission" // the game ended
+	EventMasterMode   EventType = "mastermode"   // the master mode changed from Old to New
+	EventJoin         EventType = "join"         // Client connected
+	EventLeave        EventType = "leave"        // Client disconnected
+	EventRename       EventType = "rename"       // Client changed their name from Old to New
+	EventTeamChange   EventType = "teamchange"   // Client switched from team Old to team New
+	EventPrivilege    EventType = "privilege"    // Client's privilege changed from Old to New
+	EventPlayerScore  EventType = "playerscore"  // Client's frags, deaths or flags changed
+	EventTeamScore    EventType = "teamscore"    // Team's score changed
+)
+
+// Event is a change detected between two consecutive polls of a server.
+type Event struct {
+	Type   EventType           `json:"type"`
+	Addr   string              `json:"addr"`             // the server's address as host:port
+	Time   time.Time           `json:"time"`             // when the change was detected
+	Client *extinfo.ClientInfo `json:"client,omitempty"` // the client the event is about, if any
+	Team   *extinfo.TeamScore  `json:"team,omitempty"`   // the team the event is about, if any
+	Old    string              `json:"old,omitempty"`    // the previous value, for events about a change
+	New    string              `json:"new,omitempty"`    // the new value, for events about a change
+}
+
+// Diff returns the events that explain how prev changed into cur, in a deterministic order. Both snapshots have to be of the same server.
+func Diff(prev, cur *Snapshot) (events []Event) {
+	if prev == nil || cur == nil {
+		return nil
+	}
+
+	newEvent := func(t EventType) Event {
+		return Event{Type: t, Addr: cur.Addr, Time: cur.Time}
+	}
+	change := func(t EventType, old, new string) Event {
+		e := newEvent(t)
+		e.Old, e.New = old, new
+		return e
+	}
+
+	prevInfo, curInfo := prev.BasicInfo, cur.BasicInfo
+
+	// the time left only goes up when a new game started (or the server was restarted)
+	newGame := prevInfo.Map != curInfo.Map || prevInfo.GameMode != curInfo.GameMode || curInfo.SecsLeft > prevInfo.SecsLeft
+	if newGame {
+		events = append(events, change(EventNewGame, prevInfo.Map, curInfo.Map))
+	} else if prevInfo.SecsLeft > 0 && curInfo.SecsLeft <= 0 {
+		events = append(events, newEvent(EventIntermission))
+	}
+
+	if prevInfo.MasterMode != curInfo.MasterMode {
+		events = append(events, change(EventMasterMode, prevInfo.MasterMode, curInfo.MasterMode))
+	}
+
+	for _, cn := range sortedCNs(prev.Clients, cur.Clients) {
+		before, wasThere := prev.Clients[cn]
+		after, isThere := cur.Clients[cn]
+
+		clientEvent := func(t EventType, c extinfo.ClientInfo, old, new string) {
+			e := change(t, old, new)
+			e.Client = &c
+			events = append(events, e)
+		}
+
+		switch {
+		case !wasThere:
+			clientEvent(EventJoin, after, "", "")
+		case !isThere:
+			clientEvent(EventLeave, before, "", "")
+		default:
+			if before.Name != after.Name {
+				clientEvent(EventRename, after, before.Name, after.Name)
+			}
+			if before.Team != after.Team {
+				clientEvent(EventTeamChange, after, before.Team, after.Team)
+			}
+			if before.Privilege != after.Privilege {
+				clientEvent(EventPrivilege, after, before.Privilege, after.Privilege)
+			}
+			// everybody's score is reset when a new game starts, that's not worth an event per player
+			if !newGame && (before.Frags != after.Frags || before.Deaths != after.Deaths || before.Flags != after.Flags) {
+				clientEvent(EventPlayerScore, after, "", "")
+			}
+		}
+	}
+
+	if cur.TeamScores != nil && !newGame {
+		names := make([]string, 0, len(cur.TeamScores.Scores))
+		for name := range cur.TeamScores.Scores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			team := cur.TeamScores.Scores[name]
+			if prev.TeamScores != nil {
+				if before, ok := prev.TeamScores.Scores[name]; ok && before.Score == team.Score {
+					continue
+				}
+			}
+			e := newEvent(EventTeamScore)
+			e.Team = &team
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// returns the CNs present in a or b, in ascending order
+func sortedCNs(a, b map[int]extinfo.ClientInfo) []int {
+	cns := make([]int, 0, len(a)+len(b))
+	for cn := range a {
+		cns = append(cns, cn)
+	}
+	for cn := range b {
+		if _, ok := a[cn]; !ok {
+			cns = append(cns, cn)
+		}
+	}
+	sort.Ints(cns)
+	return cns
+}
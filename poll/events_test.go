@@ -0,0 +1,175 @@
+package poll
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func client(cn int, name, team string, frags int) extinfo.ClientInfo {
+	c := extinfo.ClientInfo{Privilege: "none", State: "alive"}
+	c.ClientNum, c.Name, c.Team, c.Frags = cn, name, team, frags
+	return c
+}
+
+func snapshot(mapName string, secsLeft int, teams map[string]int, clients ...extinfo.ClientInfo) *Snapshot {
+	snap := &Snapshot{Addr: "127.0.0.1:28785", Clients: map[int]extinfo.ClientInfo{}}
+	snap.BasicInfo.Map = mapName
+	snap.BasicInfo.GameMode = "insta ctf"
+	snap.BasicInfo.MasterMode = "open"
+	snap.BasicInfo.SecsLeft = secsLeft
+
+	if teams != nil {
+		snap.TeamScores = &extinfo.TeamScores{TeamScoresRaw: extinfo.TeamScoresRaw{Scores: map[string]extinfo.TeamScore{}}}
+		for name, score := range teams {
+			snap.TeamScores.Scores[name] = extinfo.TeamScore{Name: name, Score: score}
+		}
+	}
+
+	for _, c := range clients {
+		snap.Clients[c.ClientNum] = c
+	}
+	return snap
+}
+
+func eventTypes(events []Event) []EventType {
+	types := []EventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestDiff(t *testing.T) {
+	alice := client(0, "alice", "good", 5)
+	bob := client(1, "bob", "evil", 3)
+	teams := map[string]int{"good": 1, "evil": 0}
+
+	renamed := client(0, "alicia", "evil", 5)
+	promoted := bob
+	promoted.Privilege = "master"
+	scored := client(0, "alice", "good", 6)
+
+	tests := []struct {
+		name     string
+		prev     *Snapshot
+		cur      *Snapshot
+		expected []EventType
+	}{
+		{"first snapshot", nil, snapshot("forge", 300, teams, alice), nil},
+		{"nothing changed", snapshot("forge", 300, teams, alice), snapshot("forge", 290, teams, alice), nil},
+		{"join and leave", snapshot("forge", 300, teams, alice), snapshot("forge", 290, teams, bob), []EventType{EventLeave, EventJoin}},
+		{"rename and team change", snapshot("forge", 300, teams, alice), snapshot("forge", 290, teams, renamed), []EventType{EventRename, EventTeamChange}},
+		{"privilege", snapshot("forge", 300, teams, bob), snapshot("forge", 290, teams, promoted), []EventType{EventPrivilege}},
+		{"scores", snapshot("forge", 300, teams, alice), snapshot("forge", 290, map[string]int{"good": 2, "evil": 0}, scored), []EventType{EventPlayerScore, EventTeamScore}},
+		{"intermission", snapshot("forge", 10, teams, alice), snapshot("forge", 0, teams, alice), []EventType{EventIntermission}},
+		{"new map", snapshot("forge", 0, teams, alice), snapshot("reissen", 600, map[string]int{"good": 0, "evil": 0}, client(0, "alice", "good", 0)), []EventType{EventNewGame}},
+		{"same map again", snapshot("forge", 0, teams, alice), snapshot("forge", 600, teams, alice), []EventType{EventNewGame}},
+	}
+
+	for _, test := range tests {
+		if types := eventTypes(Diff(test.prev, test.cur)); len(types) > 0 || len(test.expected) > 0 {
+			if !reflect.DeepEqual(types, test.expected) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, types)
+			}
+		}
+	}
+
+	events := Diff(snapshot("forge", 300, teams, alice), snapshot("forge", 290, teams, renamed))
+	if events[0].Client == nil || events[0].Client.ClientNum != 0 || events[0].Old != "alice" || events[0].New != "alicia" {
+		t.Errorf("unexpected rename event %+v", events[0])
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(time.Minute, s)
+	updates, unsubscribe := p.Subscribe()
+
+	p.PollAll()
+	update := <-updates
+	if update.State.Snapshot == nil || len(update.Events) != 0 {
+		t.Fatalf("unexpected first update %+v", update)
+	}
+
+	state := fakeserver.DefaultState()
+	state.Map = "reissen"
+	state.Clients = state.Clients[1:]
+	fake.SetState(state)
+
+	p.PollAll()
+	update = <-updates
+	expected := []EventType{EventNewGame, EventLeave}
+	if types := eventTypes(update.Events); !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %v, got %v", expected, types)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-updates; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+	p.PollAll()
+}
+
+func TestSubscribeSlow(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(time.Minute, s)
+	lossy, unsubscribeLossy := p.Subscribe()
+	lossless, unsubscribeLossless := p.Subscribe(Lossless())
+
+	// neither subscriber receives while the buffers fill up and overflow
+	buffered := 4*1 + 16
+	polls := buffered + 5
+	for range polls {
+		p.PollAll()
+	}
+
+	if dropped := p.Dropped(); dropped != 5 {
+		t.Errorf("expected 5 dropped updates, got %d", dropped)
+	}
+
+	for range buffered {
+		<-lossy
+	}
+	p.PollAll()
+	if update := <-lossy; update.Dropped != 5 {
+		t.Errorf("expected update to report 5 dropped updates, got %d", update.Dropped)
+	}
+	unsubscribeLossy()
+
+	unsubscribeLossless()
+	received := 0
+	for update := range lossless {
+		if update.Dropped != 0 {
+			t.Errorf("lossless update reports %d dropped updates", update.Dropped)
+		}
+		received++
+	}
+	if received != polls+1 {
+		t.Errorf("expected %d updates from lossless subscription, got %d", polls+1, received)
+	}
+}
@@ -0,0 +1,266 @@
+package poll
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+)
+
+// State is what a Poller knows about a server.
+type State struct {
+	Addr      string    `json:"addr"`                // the server's address as host:port
+	Snapshot  *Snapshot `json:"snapshot"`            // the most recent successful snapshot, nil if there was none yet
+	LastPoll  time.Time `json:"lastPoll"`            // when the server was last queried, whether successfully or not
+	LastError string    `json:"lastError,omitempty"` // why the last query failed, "" if it succeeded
+}
+
+// Poller queries a set of servers at a fixed interval and keeps the most recent state of each.
+type Poller struct {
+	interval time.Duration
+	servers  []*extinfo.Server
+
+	mutex  sync.RWMutex
+	states map[string]*State
+
+	subscribersMutex sync.Mutex
+	subscribers      map[*subscriber]struct{}
+	dropped          int // updates dropped for subscribers that didn't keep up
+}
+
+// Update is sent to subscribers after every poll of a server.
+type Update struct {
+	State   State   // the server's state after the poll
+	Events  []Event // what changed since the previous poll, may be empty
+	Dropped int     // updates this subscriber didn't keep up with since the previous one it received, which were dropped; always 0 for lossless subscriptions
+}
+
+// New returns a Poller querying servers every interval, once started using Run().
+func New(interval time.Duration, servers ...*extinfo.Server) *Poller {
+	p := &Poller{
+		interval:    interval,
+		servers:     servers,
+		states:      map[string]*State{},
+		subscribers: map[*subscriber]struct{}{},
+	}
+
+	for _, s := range servers {
+		addr := s.Addr()
+		p.states[addr.String()] = &State{Addr: addr.String()}
+	}
+
+	return p
+}
+
+// Run polls all servers immediately and then every interval, until ctx is cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.PollAll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollAll queries all servers concurrently and updates their states.
+func (p *Poller) PollAll() {
+	wg := sync.WaitGroup{}
+	for _, s := range p.servers {
+		wg.Add(1)
+		go func(s *extinfo.Server) {
+			defer wg.Done()
+			p.poll(s)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (p *Poller) poll(s *extinfo.Server) {
+	addr := s.Addr()
+	snap, err := Query(s)
+
+	p.mutex.Lock()
+	state := p.states[addr.String()]
+	wasUp := state.Snapshot != nil && state.LastError == ""
+	wasDown := !state.LastPoll.IsZero() && state.LastError != ""
+	state.LastPoll = time.Now()
+
+	var events []Event
+	if err != nil {
+		state.LastError = err.Error()
+		if wasUp {
+			events = append(events, Event{Type: EventOffline, Addr: state.Addr, Time: state.LastPoll, New: state.LastError})
+		}
+	} else {
+		if wasDown {
+			events = append(events, Event{Type: EventOnline, Addr: state.Addr, Time: state.LastPoll})
+		}
+		events = append(events, Diff(state.Snapshot, snap)...)
+		state.Snapshot = snap
+		state.LastError = ""
+	}
+
+	update := Update{State: *state, Events: events}
+	p.mutex.Unlock()
+
+	p.publish(update)
+}
+
+// SubscribeOption changes how a subscription delivers updates.
+type SubscribeOption func(*subscriber)
+
+// Lossless makes a subscription queue the updates its subscriber didn't receive yet, however many, instead of dropping them. It is meant for subscribers that have to see every poll, e.g. to record them, and that keep receiving until the channel is closed; the queue grows for as long as the subscriber falls behind.
+func Lossless() SubscribeOption {
+	return func(sub *subscriber) {
+		sub.lossless = true
+	}
+}
+
+// Subscribe returns a channel receiving an Update after every poll of any server, and a function to cancel the subscription, which closes the channel.
+// The channel buffers a few rounds of polls, but unless the subscription is Lossless(), updates are dropped for subscribers that don't keep up, so it should be drained promptly. The next update received tells how many were dropped.
+func (p *Poller) Subscribe(options ...SubscribeOption) (updates <-chan Update, unsubscribe func()) {
+	sub := &subscriber{ch: make(chan Update, 4*len(p.servers)+16)}
+	for _, option := range options {
+		option(sub)
+	}
+	if sub.lossless {
+		sub.queued = sync.NewCond(&sub.mutex)
+		go sub.forward()
+	}
+
+	p.subscribersMutex.Lock()
+	p.subscribers[sub] = struct{}{}
+	p.subscribersMutex.Unlock()
+
+	once := sync.Once{}
+	unsubscribe = func() {
+		once.Do(func() {
+			p.subscribersMutex.Lock()
+			delete(p.subscribers, sub)
+			p.subscribersMutex.Unlock()
+			sub.close()
+		})
+	}
+
+	return sub.ch, unsubscribe
+}
+
+// Dropped returns how many updates were dropped in total for subscribers that didn't keep up.
+func (p *Poller) Dropped() int {
+	p.subscribersMutex.Lock()
+	defer p.subscribersMutex.Unlock()
+	return p.dropped
+}
+
+// sends update to all subscribers without blocking
+func (p *Poller) publish(update Update) {
+	p.subscribersMutex.Lock()
+	defer p.subscribersMutex.Unlock()
+
+	for sub := range p.subscribers {
+		if sub.lossless {
+			sub.enqueue(update)
+			continue
+		}
+
+		lossy := update
+		lossy.Dropped = sub.dropped
+		select {
+		case sub.ch <- lossy:
+			sub.dropped = 0
+		default:
+			// subscriber is too slow
+			sub.dropped++
+			p.dropped++
+		}
+	}
+}
+
+type subscriber struct {
+	ch       chan Update
+	lossless bool
+	dropped  int // since the last update sent, only used while holding the poller's subscribersMutex
+
+	// updates of lossless subscriptions wait here until forward() sends them
+	mutex  sync.Mutex
+	queued *sync.Cond
+	queue  []Update
+	closed bool
+}
+
+func (sub *subscriber) enqueue(update Update) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	sub.queue = append(sub.queue, update)
+	sub.queued.Signal()
+}
+
+// sends the queued updates of a lossless subscription in order, and closes the channel once it was closed and all of them were sent
+func (sub *subscriber) forward() {
+	defer close(sub.ch)
+
+	for {
+		sub.mutex.Lock()
+		for len(sub.queue) == 0 && !sub.closed {
+			sub.queued.Wait()
+		}
+		queue, closed := sub.queue, sub.closed
+		sub.queue = nil
+		sub.mutex.Unlock()
+
+		if len(queue) == 0 && closed {
+			return
+		}
+		for _, update := range queue {
+			sub.ch <- update
+		}
+	}
+}
+
+// closes the channel, after forward() sent the queued updates of a lossless subscription
+func (sub *subscriber) close() {
+	if !sub.lossless {
+		close(sub.ch)
+		return
+	}
+
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	sub.closed = true
+	sub.queued.Signal()
+}
+
+// State returns a copy of the state of the server at addr, which has to be given as host:port.
+func (p *Poller) State(addr string) (State, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	state, ok := p.states[addr]
+	if !ok {
+		return State{}, false
+	}
+	return *state, true
+}
+
+// States returns a copy of the state of every server, sorted by address.
+func (p *Poller) States() []State {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	states := make([]State, 0, len(p.states))
+	for _, state := range p.states {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Addr < states[j].Addr })
+
+	return states
+}
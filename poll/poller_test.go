@@ -0,0 +1,89 @@
+package poll
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func TestPoller(t *testing.T) {
+	fake, err := fakeserver.Start(fakeserver.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// queries go to port 1, where nothing listens
+	unreachable, err := extinfo.NewServer(net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(time.Minute, s, unreachable)
+
+	addr := fake.Addr()
+	state, ok := p.State(addr.String())
+	if !ok {
+		t.Fatal("server unknown before first poll")
+	}
+	if state.Snapshot != nil {
+		t.Error("snapshot before first poll")
+	}
+
+	p.PollAll()
+
+	state, _ = p.State(addr.String())
+	if state.Snapshot == nil {
+		t.Fatalf("no snapshot after poll, last error: %s", state.LastError)
+	}
+	if state.Snapshot.BasicInfo.Map != "forge" || state.Snapshot.Uptime != 3600 || len(state.Snapshot.Clients) != 4 {
+		t.Errorf("unexpected snapshot %+v", state.Snapshot)
+	}
+	if state.Snapshot.TeamScores == nil || len(state.Snapshot.TeamScores.Scores) != 2 {
+		t.Errorf("unexpected team scores %+v", state.Snapshot.TeamScores)
+	}
+
+	states := p.States()
+	if len(states) != 2 {
+		t.Fatalf("expected 2 states, got %d", len(states))
+	}
+	for _, state := range states {
+		if state.Addr != addr.String() && (state.LastError == "" || state.Snapshot != nil) {
+			t.Errorf("expected unreachable server to have failed, got %+v", state)
+		}
+	}
+}
+
+func TestQueryColorCodes(t *testing.T) {
+	state := fakeserver.DefaultState()
+	state.Description = " \f3red \f7server "
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Query(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.BasicInfo.Description != state.Description {
+		t.Errorf("expected color codes to be kept, got %q", snap.BasicInfo.Description)
+	}
+	if snap.Description() != "red server" {
+		t.Errorf("expected plain description, got %q", snap.Description())
+	}
+}
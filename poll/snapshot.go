@@ -0,0 +1,76 @@
+// Package poll periodically queries Sauerbraten servers and keeps the most recent state of each.
+package poll
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sauerbraten/cubecode"
+
+	"github.com/sauerbraten/extinfo"
+)
+
+// Snapshot is the state of a server at one point in time.
+type Snapshot struct {
+	Time       time.Time                  `json:"time"`                 // when the server was queried
+	Addr       string                     `json:"addr"`                 // the server's address as host:port, as used to connect in game
+	Ping       int                        `json:"ping"`                 // milliseconds it took the server to answer the basic info request
+	BasicInfo  extinfo.BasicInfo          `json:"basicInfo"`            // the description keeps its color codes, see Description()
+	Uptime     int                        `json:"uptime"`               // seconds since the server was started
+	Mod        string                     `json:"mod"`                  // the server mod, "" if none was detected
+	TeamScores *extinfo.TeamScores        `json:"teamScores,omitempty"` // nil if no team mode is being played
+	Clients    map[int]extinfo.ClientInfo `json:"clients"`              // all clients, including spectators and bots, mapped to their CN
+}
+
+// Query queries everything there is to know about s and returns it as a Snapshot.
+func Query(s *extinfo.Server) (*Snapshot, error) {
+	addr := s.Addr()
+	snap := &Snapshot{
+		Time: time.Now(),
+		Addr: addr.String(),
+	}
+
+	var err error
+
+	// color codes are kept for those rendering them, but only in the description: map names are compared and stored as they are
+	snap.BasicInfo, err = s.GetBasicInfo(extinfo.KeepColorCodes(true))
+	if err != nil {
+		return nil, err
+	}
+	snap.BasicInfo.Map = cubecode.SanitizeString(snap.BasicInfo.Map)
+	snap.Ping = int(time.Since(snap.Time).Milliseconds())
+
+	snap.Uptime, err = s.GetUptime()
+	if err != nil {
+		return nil, err
+	}
+
+	snap.Mod, err = s.GetServerMod()
+	if err != nil {
+		return nil, err
+	}
+
+	if extinfo.IsTeamMode(snap.BasicInfo.GameMode) {
+		var teamScores extinfo.TeamScores
+		teamScores, err = s.GetTeamScores()
+		// the game mode might have changed in the meantime
+		if err != nil && !errors.Is(err, extinfo.ErrNotTeamMode) {
+			return nil, err
+		}
+		if err == nil {
+			snap.TeamScores = &teamScores
+		}
+	}
+
+	snap.Clients, err = s.GetAllClientInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// Description returns the server's description without color codes (and surrounding whitespace), as GetBasicInfo() returns it by default.
+func (snap *Snapshot) Description() string {
+	return cubecode.SanitizeString(snap.BasicInfo.Description)
+}
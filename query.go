@@ -1,10 +1,10 @@
 package extinfo
 
 import (
-	"bufio"
+	"bytes"
 	"errors"
-	"net"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/sauerbraten/cubecode"
@@ -12,76 +12,112 @@ import (
 
 // builds a request
 func buildRequest(infoType byte, extendedInfoType byte, clientNum int) []byte {
-	request := []byte{}
-
 	// extended info request
 	if infoType == InfoTypeExtended {
-		request = append(request, infoType, extendedInfoType)
-
 		// client stats has to include the clientNum (-1 for all)
 		if extendedInfoType == ExtInfoTypeClientInfo {
-			request = append(request, byte(clientNum))
+			return buildExtendedRequest(extendedInfoType, clientNum)
 		}
+
+		return buildExtendedRequest(extendedInfoType)
 	}
 
 	// basic info request
-	if infoType == InfoTypeBasic {
-		request = append(request, byte(infoType))
+	return []byte{infoType}
+}
+
+// builds an extended info request for command, followed by args
+func buildExtendedRequest(command byte, args ...int) []byte {
+	request := []byte{InfoTypeExtended, command}
+
+	for _, arg := range args {
+		request = appendInt(request, arg)
 	}
 
 	return request
 }
 
-// queries the given server and returns the response and an error in case something went wrong. clientNum is optional, put 0 if not needed.
-func (s *Server) queryServer(request []byte) (response *cubecode.Packet, err error) {
-	// connect to server at port+1 (port is the port you connect to in game, sauerbraten listens on the one higher port for BasicInfo queries
-	var conn *net.UDPConn
-	conn, err = net.DialUDP("udp", nil, s.addr)
+// appends value to buf using cubecode's compressed integer encoding (the one the server's getint() decodes)
+func appendInt(buf []byte, value int) []byte {
+	switch {
+	case value < 0x80 && value > -0x7F:
+		return append(buf, byte(value))
+	case value < 0x8000 && value >= -0x8000:
+		return append(buf, 0x80, byte(value), byte(value>>8))
+	default:
+		return append(buf, 0x81, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+	}
+}
+
+// QueryExtended sends the extended info request command with the given arguments and returns the response with the replayed request, ACK, version and error byte already read and validated. Responses to ExtInfoTypeUptime do not contain an error byte, so the returned packet starts right after the version.
+// Use this to send commands some server mods answer in addition to the ones supported by this package. Only the first datagram of the response is returned.
+func (s *Server) QueryExtended(command byte, args ...int) (response *cubecode.Packet, err error) {
+	err = s.queryServer(buildExtendedRequest(command, args...), func(packet *cubecode.Packet) error {
+		// copy, since the buffer is reused once this function returns
+		response = cubecode.NewPacket(packetBytes(packet))
+		return nil
+	})
+	return
+}
+
+// buffers big enough for any datagram, shared by all queries
+var datagramBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, maxDatagramLength)
+		return &buf
+	},
+}
+
+// sends request to the server and calls handle with a buffer to read the response into, which must not be used after handle returned
+func (s *Server) roundTrip(request []byte, handle func(conn Conn, buf []byte) error) error {
+	conn, err := s.transport.Open(s.addr, request)
 	if err != nil {
-		return
+		return err
 	}
 	defer conn.Close()
 
-	// set up a buffered reader
-	bufconn := bufio.NewReader(conn)
+	buf := datagramBuffers.Get().(*[]byte)
+	defer datagramBuffers.Put(buf)
 
-	// send the request to server
-	_, err = conn.Write(request)
-	if err != nil {
-		return
+	return handle(conn, *buf)
+}
+
+// reads one datagram from conn into buf, waiting no longer than the server's time out, or until its deadline
+func (s *Server) readDatagramInto(conn Conn, buf []byte) ([]byte, error) {
+	timeOut := s.timeOut
+	if !s.deadline.IsZero() {
+		timeOut = min(timeOut, time.Until(s.deadline))
 	}
 
-	// receive response from server with 5 second timeout
-	rawResponse := make([]byte, MaxPacketLength)
-	var bytesRead int
-	conn.SetReadDeadline(time.Now().Add(s.timeOut))
-	bytesRead, err = bufconn.Read(rawResponse)
+	bytesRead, err := conn.Read(buf, timeOut)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	// trim response to what's actually from the server
-	packet := cubecode.NewPacket(rawResponse[:bytesRead])
+	return buf[:bytesRead], nil
+}
 
-	// response must include the entire request, ExtInfoAck, ExtInfoVersion, and either ExtInfoError or uptime
-	if bytesRead < len(request)+3 {
-		err = errors.New("extinfo: invalid response: too short")
-		return
+// returns the unread bytes of p, without consuming them
+func packetBytes(p *cubecode.Packet) []byte {
+	b := make([]byte, p.Len())
+	for i := range b {
+		b[i], _ = p.ReadByte()
 	}
+	return b
+}
 
-	infoType, err := packet.ReadByte()
-	if err != nil {
-		return
-	}
+// validates the beginning of a response to an extended info request and returns the rest of it. commandError is true if the server signalled an error processing the command.
+func parseExtendedResponseHeader(request []byte, rawResponse []byte) (response *cubecode.Packet, commandError bool, err error) {
+	command := request[1]
 
-	// end of basic info response handling
-	if infoType == InfoTypeBasic {
-		response, err = packet.SubPacketFromRemaining()
+	// response must include the entire request, ExtInfoAck, ExtInfoVersion, and either ExtInfoError or uptime
+	if len(rawResponse) < len(request)+3 {
+		err = errors.New("extinfo: invalid response: too short")
 		return
 	}
 
 	// make sure the entire request is correctly replayed
-
 	for i, b := range request {
 		if rawResponse[i] != b {
 			err = errors.New("extinfo: invalid response: response does not match request")
@@ -89,21 +125,10 @@ func (s *Server) queryServer(request []byte) (response *cubecode.Packet, err err
 		}
 	}
 
-	command, err := packet.ReadByte()
-	if err != nil {
-		return
-	}
-
-	// skip rest of request
-	for i := 2; i < len(request); i++ {
-		_, err = packet.ReadByte()
-		if err != nil {
-			return
-		}
-	}
+	response = cubecode.NewPacket(rawResponse[len(request):])
 
 	// validate ack
-	ack, err := packet.ReadByte()
+	ack, err := response.ReadByte()
 	if err != nil {
 		return
 	}
@@ -113,7 +138,7 @@ func (s *Server) queryServer(request []byte) (response *cubecode.Packet, err err
 	}
 
 	// validate version
-	version, err := packet.ReadByte()
+	version, err := response.ReadByte()
 	if err != nil {
 		return
 	}
@@ -123,71 +148,229 @@ func (s *Server) queryServer(request []byte) (response *cubecode.Packet, err err
 		return
 	}
 
-	// end of uptime request handling
+	// uptime responses have no error byte
 	if command == ExtInfoTypeUptime {
-		response, err = packet.SubPacketFromRemaining()
 		return
 	}
 
-	commandError, err := packet.ReadByte()
+	errorByte, err := response.ReadByte()
 	if err != nil {
 		return
 	}
 
-	if commandError == ExtInfoError {
-		switch command {
-		case ExtInfoTypeClientInfo:
-			err = errors.New("extinfo: no client with cn " + strconv.Itoa(int(request[2])))
-		case ExtInfoTypeTeamScores:
-			err = errors.New("extinfo: server is not running a team mode")
+	commandError = errorByte == ExtInfoError
+	return
+}
+
+// sends request to the server and calls parse with the first datagram of the response, minus the parts validated here: the replayed request and, for extended info requests, ACK, version and error byte. The packet must not be used after parse returned.
+func (s *Server) queryServer(request []byte, parse func(response *cubecode.Packet) error) error {
+	return s.roundTrip(request, func(conn Conn, buf []byte) error {
+		rawResponse, err := s.readDatagramInto(conn, buf)
+		if err != nil {
+			return err
 		}
-		return
-	}
 
-	// end of team scores request handling
-	if command == ExtInfoTypeTeamScores {
-		response, err = packet.SubPacketFromRemaining()
-		return
-	}
+		// end of basic info response handling
+		if request[0] == InfoTypeBasic {
+			if len(rawResponse) < len(request) {
+				return errors.New("extinfo: invalid response: too short")
+			}
+			if !bytes.Equal(rawResponse[:len(request)], request) {
+				return errors.New("extinfo: invalid response: response does not match request")
+			}
 
-	// handle response to ExtInfoTypeClientInfo
+			return parse(cubecode.NewPacket(rawResponse[len(request):]))
+		}
+
+		command := request[1]
+
+		response, commandError, err := parseExtendedResponseHeader(request, rawResponse)
+		if err != nil {
+			return err
+		}
 
-	// some server mods silently fail to implement responses → fail gracefully
-	clientNumsHeader, err := packet.ReadByte()
+		if commandError {
+			if command == ExtInfoTypeTeamScores {
+				return ErrNotTeamMode
+			}
+			return errors.New("extinfo: server returned an error for command " + strconv.Itoa(int(command)))
+		}
+
+		return parse(response)
+	})
+}
+
+// queries the server for information about the client with clientNum (-1 for all clients) and returns one packet per client, each starting at the client's CN. The packets are returned in the order the server listed the CNs in, regardless of the order their datagrams arrived in.
+func (s *Server) queryClientInfo(clientNum int) (responses []*cubecode.Packet, err error) {
+	received := map[int]*cubecode.Packet{}
+	clientNums, err := s.streamClientInfo(clientNum, func(cn int, response *cubecode.Packet) bool {
+		// copy, since the buffer is reused for the next datagram
+		received[cn] = cubecode.NewPacket(packetBytes(response))
+		return true
+	})
 	if err != nil {
 		return
 	}
-	if clientNumsHeader != ClientInfoResponseTypeCNs {
-		err = errors.New("extinfo: invalid response: expected " + strconv.Itoa(int(ExtInfoVersion)) + ", got " + strconv.Itoa(int(version)))
-		return
+
+	responses = make([]*cubecode.Packet, 0, len(clientNums))
+	for _, cn := range clientNums {
+		responses = append(responses, received[cn])
 	}
 
-	// count (but discard) CNs
-	numberOfClients := 0
+	return
+}
+
+// queries the server for information about the client with clientNum (-1 for all clients) and calls yield with each client's CN and packet (starting at the CN) as soon as its datagram arrived, until yield returns false. The packet must not be used after yield returned. Returns the CNs the server listed, in its order.
+// The datagram listing the CNs is usually the first one to arrive, but UDP doesn't guarantee that: client datagrams arriving before it are kept until the CNs are known.
+func (s *Server) streamClientInfo(clientNum int, yield func(cn int, response *cubecode.Packet) bool) (clientNums []int, err error) {
+	request := buildExtendedRequest(ExtInfoTypeClientInfo, clientNum)
+
+	err = s.roundTrip(request, func(conn Conn, buf []byte) error {
+		// bodies of client datagrams that arrived before the list of CNs, copied since buf is reused
+		early := [][]byte{}
+
+		for clientNums == nil {
+			rawResponse, err := s.readDatagramInto(conn, buf)
+			if err != nil {
+				return err
+			}
+
+			packet, commandError, err := parseExtendedResponseHeader(request, rawResponse)
+			if err != nil {
+				return err
+			}
+
+			if commandError {
+				return UnknownClientError{clientNum}
+			}
+
+			// some server mods silently fail to implement responses → fail gracefully
+			header, err := packet.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			switch header {
+			case ClientInfoResponseTypeCNs:
+				// the CNs of all clients we will receive a packet for
+				clientNums, err = parseClientNums(packet)
+				if err != nil {
+					return err
+				}
+			case ClientInfoResponseTypeInfo:
+				if len(early) == MaxClients {
+					return errors.New("extinfo: invalid response: more than " + strconv.Itoa(MaxClients) + " client datagrams before the list of CNs")
+				}
+				early = append(early, packetBytes(packet))
+			default:
+				return errors.New("extinfo: invalid response: expected " + strconv.Itoa(int(ClientInfoResponseTypeCNs)) + ", got " + strconv.Itoa(int(header)))
+			}
+		}
+
+		// whether the datagram of the client at the same index in clientNums was received yet
+		received := make([]bool, len(clientNums))
+		remaining := len(clientNums)
+
+		// duplicates of datagrams we tolerate; without a limit, a server could keep us reading forever
+		duplicatesLeft := len(clientNums)
+
+		// handles the body of a client datagram, starting at the CN; done is true once yield returned false
+		handle := func(body []byte) (done bool, err error) {
+			packet := cubecode.NewPacket(body)
+			cn, err := packet.ReadInt()
+			if err != nil {
+				return false, err
+			}
+
+			i := indexOf(clientNums, cn)
+			if i < 0 {
+				return false, errors.New("extinfo: invalid response: unexpected client info for cn " + strconv.Itoa(cn))
+			}
+			if received[i] {
+				if duplicatesLeft == 0 {
+					return false, errors.New("extinfo: invalid response: too many duplicate datagrams")
+				}
+				duplicatesLeft--
+				return false, nil
+			}
+
+			received[i] = true
+			remaining--
+
+			return !yield(cn, cubecode.NewPacket(body)), nil
+		}
+
+		for _, body := range early {
+			if done, err := handle(body); done || err != nil {
+				return err
+			}
+		}
+
+		// receive one datagram per client; they may arrive in any order
+		for remaining > 0 {
+			rawResponse, err := s.readDatagramInto(conn, buf)
+			if err != nil {
+				return err
+			}
+
+			packet, commandError, err := parseExtendedResponseHeader(request, rawResponse)
+			if err != nil {
+				return err
+			}
+			if commandError {
+				return errors.New("extinfo: invalid response: error in client info packet")
+			}
+
+			infoHeader, err := packet.ReadByte()
+			if err != nil {
+				return err
+			}
+			if infoHeader != ClientInfoResponseTypeInfo {
+				return errors.New("extinfo: invalid response: expected " + strconv.Itoa(int(ClientInfoResponseTypeInfo)) + ", got " + strconv.Itoa(int(infoHeader)))
+			}
+
+			if done, err := handle(rawResponse[len(rawResponse)-packet.Len():]); done || err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// parses the list of CNs the server will send client info for, which makes up the rest of packet
+func parseClientNums(packet *cubecode.Packet) (clientNums []int, err error) {
+	clientNums = make([]int, 0, min(packet.Len(), MaxClients))
 	for packet.HasRemaining() {
-		_, err = packet.ReadInt()
+		var cn int
+		cn, err = packet.ReadInt()
 		if err != nil {
 			return
 		}
 
-		numberOfClients++
-	}
-
-	// for each client, receive a packet and append it to a new slice
-	clientInfos := make([]byte, 0, MaxPacketLength*numberOfClients)
-	for i := 0; i < numberOfClients; i++ {
-		// read from connection
-		clientInfo := make([]byte, MaxPacketLength)
-		conn.SetReadDeadline(time.Now().Add(s.timeOut))
-		_, err = bufconn.Read(clientInfo)
-		if err != nil {
+		if len(clientNums) == MaxClients {
+			err = errors.New("extinfo: invalid response: more than " + strconv.Itoa(MaxClients) + " clients")
+			return
+		}
+		if indexOf(clientNums, cn) >= 0 {
+			err = errors.New("extinfo: invalid response: cn " + strconv.Itoa(cn) + " listed twice")
 			return
 		}
 
-		// append bytes to slice
-		clientInfos = append(clientInfos, clientInfo...)
+		clientNums = append(clientNums, cn)
 	}
 
-	response = cubecode.NewPacket(clientInfos)
 	return
 }
+
+// returns the index of the first occurence of value in values, or -1
+func indexOf(values []int, value int) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
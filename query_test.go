@@ -0,0 +1,255 @@
+package extinfo
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func TestBuildExtendedRequest(t *testing.T) {
+	tests := []struct {
+		clientNum int
+		expected  []byte
+	}{
+		{-1, []byte{0x00, 0x01, 0xFF}},
+		{0, []byte{0x00, 0x01, 0x00}},
+		{126, []byte{0x00, 0x01, 0x7E}},
+		{MaxPlayerCN, []byte{0x00, 0x01, 0x7F}},
+		{MaxPlayerCN + 1, []byte{0x00, 0x01, 0x80, 0x80, 0x00}},
+		{255, []byte{0x00, 0x01, 0x80, 0xFF, 0x00}},
+		{256, []byte{0x00, 0x01, 0x80, 0x00, 0x01}},
+		{-126, []byte{0x00, 0x01, 0x82}},
+		{-127, []byte{0x00, 0x01, 0x80, 0x81, 0xFF}},
+		{0x8000, []byte{0x00, 0x01, 0x81, 0x00, 0x80, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		request := buildExtendedRequest(ExtInfoTypeClientInfo, test.clientNum)
+		if !bytes.Equal(request, test.expected) {
+			t.Errorf("cn %d: expected request % X, got % X", test.clientNum, test.expected, request)
+		}
+	}
+}
+
+func TestGetClientInfoBoundary(t *testing.T) {
+	for _, cn := range []int{0, 10, MaxPlayerCN + 1} {
+		clientInfo, err := srv.GetClientInfo(cn)
+		if err != nil {
+			t.Errorf("cn %d: %v", cn, err)
+			continue
+		}
+		if clientInfo.ClientNum != cn {
+			t.Errorf("requested cn %d, got cn %d", cn, clientInfo.ClientNum)
+		}
+	}
+
+	_, err := srv.GetClientInfo(MaxPlayerCN)
+	if err == nil || err.Error() != "extinfo: no client with cn 127" {
+		t.Errorf("expected error for missing cn 127, got %v", err)
+	}
+}
+
+func TestGetAllClientInfoFraming(t *testing.T) {
+	RegisterExtensionParsers("spaghettimod", ExtensionParsers{ClientInfo: parseTestExtension})
+	defer RegisterExtensionParsers("spaghettimod", ExtensionParsers{})
+
+	state := fakeserver.DefaultState()
+	state.Mod = -4
+	state.ReverseClientInfo = true
+	// makes every client info datagram larger than MaxPacketLength
+	state.ClientInfoExtension = bytes.Repeat([]byte{0x01}, 2*MaxPacketLength)
+	s := startFakeServer(t, state)
+
+	allClientInfo, err := s.GetAllClientInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(allClientInfo) != len(state.Clients) {
+		t.Fatalf("expected %d clients, got %d", len(state.Clients), len(allClientInfo))
+	}
+
+	for _, expected := range state.Clients {
+		clientInfo, ok := allClientInfo[expected.CN]
+		if !ok {
+			t.Errorf("missing cn %d", expected.CN)
+			continue
+		}
+		if clientInfo.Name != expected.Name {
+			t.Errorf("cn %d: expected name %q, got %q", expected.CN, expected.Name, clientInfo.Name)
+		}
+		if ext, ok := clientInfo.Extension.(testExtension); !ok || len(ext.Values) != 2*MaxPacketLength {
+			t.Errorf("cn %d: extension not decoded completely", expected.CN)
+		}
+	}
+}
+
+func TestGetAllClientInfoCNsLast(t *testing.T) {
+	state := fakeserver.DefaultState()
+	state.CNsLast = true
+	s := startFakeServer(t, state)
+
+	allClientInfo, err := s.GetAllClientInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allClientInfo) != len(state.Clients) {
+		t.Fatalf("expected %d clients, got %d", len(state.Clients), len(allClientInfo))
+	}
+	for _, expected := range state.Clients {
+		if clientInfo, ok := allClientInfo[expected.CN]; !ok || clientInfo.Name != expected.Name {
+			t.Errorf("cn %d: expected %q, got %+v", expected.CN, expected.Name, clientInfo)
+		}
+	}
+
+	clientInfo, err := s.GetClientInfo(state.Clients[1].CN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientInfo.Name != state.Clients[1].Name {
+		t.Errorf("expected %q, got %q", state.Clients[1].Name, clientInfo.Name)
+	}
+
+	// early datagrams are validated against the CNs once they arrive
+	state.CNsLast = false
+	request := buildExtendedRequest(ExtInfoTypeClientInfo, -1)
+	datagrams := state.Respond(request)
+	state.Clients = state.Clients[1:2]
+	responses := []Datagram{{Data: datagrams[1]}, {Data: state.Respond(request)[0]}}
+
+	addr := net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28785}
+	info := addr
+	info.Port++
+
+	replay := &Replay{
+		exchanges: map[string][]Exchange{replayKey(&info, request): {{Responses: responses}}},
+		next:      map[string]int{},
+	}
+
+	s, err = NewServer(addr, time.Second, UseTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.GetAllClientInfo()
+	if err == nil || !strings.HasSuffix(err.Error(), "unexpected client info for cn "+strconv.Itoa(fakeserver.DefaultState().Clients[0].CN)) {
+		t.Errorf("expected error about unlisted cn, got %v", err)
+	}
+}
+
+func TestBasicInfoMismatch(t *testing.T) {
+	// a late response to an uptime request arriving instead of the basic info
+	state := fakeserver.DefaultState()
+	request := buildRequest(InfoTypeBasic, 0, 0)
+	response := state.Respond(buildExtendedRequest(ExtInfoTypeUptime, 0))[0]
+
+	addr := net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28785}
+	info := addr
+	info.Port++
+
+	replay := &Replay{
+		exchanges: map[string][]Exchange{replayKey(&info, request): {{Responses: []Datagram{{Data: response}}}}},
+		next:      map[string]int{},
+	}
+
+	s, err := NewServer(addr, time.Second, UseTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.GetBasicInfoRaw()
+	if err == nil || !strings.HasSuffix(err.Error(), "response does not match request") {
+		t.Errorf("expected mismatch error, got %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tooManyBases := fakeserver.DefaultState()
+	tooManyBases.Teams[0].Bases = make([]int, MaxBases+1)
+
+	tooManyTeams := fakeserver.DefaultState()
+	for len(tooManyTeams.Teams) <= MaxTeams {
+		tooManyTeams.Teams = append(tooManyTeams.Teams, fakeserver.Team{Name: strconv.Itoa(len(tooManyTeams.Teams))})
+	}
+
+	longDescription := fakeserver.DefaultState()
+	longDescription.Description = strings.Repeat("x", MaxStringLength+1)
+
+	duplicateCN := fakeserver.DefaultState()
+	duplicateCN.Clients = append(duplicateCN.Clients, duplicateCN.Clients[0])
+
+	tests := []struct {
+		name  string
+		state fakeserver.State
+		query func(*Server) error
+	}{
+		{"bases", tooManyBases, func(s *Server) error { _, err := s.GetTeamScoresRaw(); return err }},
+		{"teams", tooManyTeams, func(s *Server) error { _, err := s.GetTeamScoresRaw(); return err }},
+		{"string length", longDescription, func(s *Server) error { _, err := s.GetBasicInfoRaw(); return err }},
+		{"duplicate cn", duplicateCN, func(s *Server) error { _, err := s.GetAllClientInfo(); return err }},
+	}
+
+	for _, test := range tests {
+		err := test.query(startFakeServer(t, test.state))
+		if err == nil || !strings.Contains(err.Error(), "invalid response") {
+			t.Errorf("%s: expected invalid response error, got %v", test.name, err)
+		}
+	}
+
+	// within the limits
+	capture := fakeserver.DefaultState()
+	capture.Teams[0].Bases = []int{0, 2, 3}
+	capture.Description = strings.Repeat("x", MaxStringLength)
+	s := startFakeServer(t, capture)
+
+	teamScores, err := s.GetTeamScoresRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bases := teamScores.Scores["good"].Bases; !reflect.DeepEqual(bases, []int{0, 2, 3}) {
+		t.Errorf("expected bases [0 2 3], got %v", bases)
+	}
+
+	if _, err := s.GetBasicInfoRaw(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDuplicateDatagramFlood(t *testing.T) {
+	state := fakeserver.DefaultState()
+	state.Clients = state.Clients[:2]
+
+	request := buildExtendedRequest(ExtInfoTypeClientInfo, -1)
+	datagrams := state.Respond(request)
+
+	// the server only ever sends the first client's datagram
+	responses := []Datagram{{Data: datagrams[0]}}
+	for i := 0; i < 10; i++ {
+		responses = append(responses, Datagram{Data: datagrams[1]})
+	}
+
+	addr := net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28785}
+	info := addr
+	info.Port++
+
+	replay := &Replay{
+		exchanges: map[string][]Exchange{replayKey(&info, request): {{Responses: responses}}},
+		next:      map[string]int{},
+	}
+
+	s, err := NewServer(addr, time.Second, UseTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.GetAllClientInfo()
+	if err == nil || err.Error() != "extinfo: invalid response: too many duplicate datagrams" {
+		t.Errorf("expected error about duplicates, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package extinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Exchange is a request sent to a server and the datagrams the server responded with, as written by a Recorder.
+type Exchange struct {
+	Time      time.Time  `json:"time"`      // when the request was sent
+	Addr      string     `json:"addr"`      // address of the server's info port
+	Request   []byte     `json:"request"`   //
+	Responses []Datagram `json:"responses"` // in the order they were received
+}
+
+// Datagram is one datagram of a response.
+type Datagram struct {
+	After time.Duration `json:"after"` // time between sending the request and receiving the datagram
+	Data  []byte        `json:"data"`  //
+}
+
+// Recorder is a Transport passing requests on to another Transport and writing every exchange as one line of JSON, for NewReplay() to read back. It is safe for concurrent use.
+type Recorder struct {
+	transport Transport
+
+	mutex sync.Mutex
+	enc   *json.Encoder
+	err   error
+}
+
+// NewRecorder returns a Recorder sending requests using transport and writing exchanges to w.
+func NewRecorder(w io.Writer, transport Transport) *Recorder {
+	return &Recorder{
+		transport: transport,
+		enc:       json.NewEncoder(w),
+	}
+}
+
+// Open sends request using the underlying transport. The exchange is written when the returned Conn is closed.
+func (r *Recorder) Open(addr *net.UDPAddr, request []byte) (Conn, error) {
+	start := time.Now()
+
+	conn, err := r.transport.Open(addr, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordingConn{
+		Conn:     conn,
+		recorder: r,
+		start:    start,
+		exchange: Exchange{
+			Time:      start,
+			Addr:      addr.String(),
+			Request:   bytes.Clone(request),
+			Responses: []Datagram{},
+		},
+	}, nil
+}
+
+// Err returns the first error that occured writing an exchange. Exchanges are not written anymore after an error.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(exchange Exchange) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		r.err = r.enc.Encode(exchange)
+	}
+}
+
+type recordingConn struct {
+	Conn
+	recorder *Recorder
+	start    time.Time
+	exchange Exchange
+}
+
+func (c *recordingConn) Read(buf []byte, timeOut time.Duration) (int, error) {
+	n, err := c.Conn.Read(buf, timeOut)
+	if err == nil {
+		c.exchange.Responses = append(c.exchange.Responses, Datagram{
+			After: time.Since(c.start),
+			Data:  bytes.Clone(buf[:n]),
+		})
+	}
+	return n, err
+}
+
+func (c *recordingConn) Close() error {
+	err := c.Conn.Close()
+	c.recorder.write(c.exchange)
+	return err
+}
+
+// ErrNotRecorded is returned when a Replay has no recording of the request sent to a server.
+var ErrNotRecorded = errors.New("extinfo: no recorded response to request")
+
+// Replay is a Transport answering requests with the responses a Recorder recorded, without using the network. It is safe for concurrent use.
+// Requests are matched by address and content. When the same request was recorded several times, the recordings are replayed in order, and the last one is repeated once all were used. Recorded timings are taken into account without actually waiting: a datagram that arrived later after the previous one than the time out of the querying Server results in a time out error, as does reading more datagrams than were recorded.
+type Replay struct {
+	mutex     sync.Mutex
+	exchanges map[string][]Exchange
+	next      map[string]int
+	servers   []net.UDPAddr
+}
+
+// NewReplay reads exchanges written by a Recorder from r.
+func NewReplay(r io.Reader) (*Replay, error) {
+	replay := &Replay{
+		exchanges: map[string][]Exchange{},
+		next:      map[string]int{},
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var exchange Exchange
+		err := dec.Decode(&exchange)
+		if err == io.EOF {
+			return replay, nil
+		}
+		if err != nil {
+			return nil, errors.New("extinfo: error reading recorded exchange: " + err.Error())
+		}
+
+		addr, err := net.ResolveUDPAddr("udp", exchange.Addr)
+		if err != nil {
+			return nil, errors.New("extinfo: invalid address in recorded exchange: " + err.Error())
+		}
+
+		if !replay.hasServer(addr) {
+			server := *addr
+			server.Port--
+			replay.servers = append(replay.servers, server)
+		}
+		key := replayKey(addr, exchange.Request)
+		replay.exchanges[key] = append(replay.exchanges[key], exchange)
+	}
+}
+
+// OpenReplay reads the exchanges a Recorder wrote to the file at path.
+func OpenReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewReplay(f)
+}
+
+// Servers returns the addresses of the recorded servers (the ones players connect to, as passed to NewServer()), in the order they were first queried in.
+func (r *Replay) Servers() []net.UDPAddr {
+	return append([]net.UDPAddr(nil), r.servers...)
+}
+
+func (r *Replay) hasServer(addr *net.UDPAddr) bool {
+	for _, server := range r.servers {
+		if server.IP.Equal(addr.IP) && server.Port+1 == addr.Port {
+			return true
+		}
+	}
+	return false
+}
+
+// Open returns a Conn reading the recorded response to request, or ErrNotRecorded.
+func (r *Replay) Open(addr *net.UDPAddr, request []byte) (Conn, error) {
+	key := replayKey(addr, request)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	exchanges := r.exchanges[key]
+	if len(exchanges) == 0 {
+		return nil, ErrNotRecorded
+	}
+
+	i := r.next[key]
+	if i < len(exchanges)-1 {
+		r.next[key]++
+	}
+
+	return &replayConn{
+		addr:      addr,
+		responses: exchanges[i].Responses,
+	}, nil
+}
+
+func replayKey(addr *net.UDPAddr, request []byte) string {
+	return addr.String() + " " + string(request)
+}
+
+type replayConn struct {
+	addr      *net.UDPAddr
+	responses []Datagram
+	elapsed   time.Duration // time since the request was sent, as it would have passed had we waited
+}
+
+func (c *replayConn) Read(buf []byte, timeOut time.Duration) (int, error) {
+	if len(c.responses) == 0 || c.responses[0].After-c.elapsed > timeOut {
+		c.elapsed += timeOut
+		return 0, &net.OpError{Op: "read", Net: "udp", Addr: c.addr, Err: os.ErrDeadlineExceeded}
+	}
+
+	datagram := c.responses[0]
+	c.responses = c.responses[1:]
+	if datagram.After > c.elapsed {
+		c.elapsed = datagram.After
+	}
+
+	// like with UDP, datagrams too big for buf are truncated
+	return copy(buf, datagram.Data), nil
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package extinfo
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	recording := &bytes.Buffer{}
+	recorder := NewRecorder(recording, DefaultTransport)
+	live := startFakeServer(t, fakeserver.DefaultState(), UseTransport(recorder))
+
+	basicInfo, err := live.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allClientInfo, err := live.GetAllClientInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplay(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := live.Addr()
+	servers := replay.Servers()
+	if len(servers) != 1 || servers[0].String() != addr.String() {
+		t.Fatalf("expected recording of %s, got %v", addr.String(), servers)
+	}
+
+	replayed, err := NewServer(servers[0], time.Second, UseTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replayed as often as requested
+	for i := 0; i < 2; i++ {
+		replayedBasicInfo, err := replayed.GetBasicInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(replayedBasicInfo, basicInfo) {
+			t.Errorf("expected %+v, got %+v", basicInfo, replayedBasicInfo)
+		}
+
+		replayedClientInfo, err := replayed.GetAllClientInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(replayedClientInfo, allClientInfo) {
+			t.Errorf("expected %+v, got %+v", allClientInfo, replayedClientInfo)
+		}
+	}
+
+	if _, err := replayed.GetUptime(); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+}
+
+func TestReplayTimeOut(t *testing.T) {
+	addr := net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28785}
+	info := addr
+	info.Port++
+
+	request := buildRequest(InfoTypeBasic, 0, 0)
+	exchange := Exchange{
+		Addr:      info.String(),
+		Request:   request,
+		Responses: []Datagram{{After: 2 * time.Second, Data: request}},
+	}
+
+	recording := &bytes.Buffer{}
+	recorder := NewRecorder(recording, nil)
+	recorder.write(exchange)
+
+	replay, err := NewReplay(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	impatient, err := NewServer(addr, time.Second, UseTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var netErr net.Error
+	if _, err := impatient.GetBasicInfoRaw(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected time out, got %v", err)
+	}
+
+	patient, err := NewServer(addr, 3*time.Second, UseTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the response is empty, so it can't be parsed, but it must arrive
+	if _, err := patient.GetBasicInfoRaw(); errors.As(err, &netErr) {
+		t.Errorf("expected response, got %v", err)
+	}
+}
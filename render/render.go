@@ -0,0 +1,154 @@
+// Package render renders the state of a server as HTML: a compact status card and a full scoreboard.
+//
+// The templates are embedded and can be used on their own (Card, Scoreboard, Page) or as part of other templates, using the template set returned by Templates():
+//
+//	{{template "card" .}}        status card: description, map, mode, players, time left
+//	{{template "scoreboard" .}}  scoreboard: teams, players sorted like in game, spectators
+//	{{template "style"}}         a <style> element with the default look
+//	{{template "countdown"}}     a <script> element counting down the time left
+//
+// All of them are executed with the value returned by NewView.
+package render
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/colors"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"formatSeconds": formatSeconds,
+}).ParseFS(templateFS, "templates/*.html"))
+
+// Templates returns a copy of the template set, to add own templates to.
+func Templates() *template.Template {
+	return template.Must(templates.Clone())
+}
+
+// View is what the templates are executed with.
+type View struct {
+	Addr             string        // the server's address as host:port
+	Description      template.HTML // the server's description, with color codes rendered
+	PlainDescription string        // the server's description without color codes, e.g. for the page title
+	Map              string        //
+	Mode             string        // game mode, e.g. "insta ctf"
+	MasterMode       string        //
+	SecsLeft         int           // seconds until intermission
+	Paused           bool          //
+	Intermission     bool          //
+	Clients          int           // number of clients, as reported by the server
+	MaxClients       int           //
+	FlagMode         bool          // whether flags are shown
+	TeamMode         bool          // whether Teams has one entry per team, or a single one without a name
+	Teams            []Team        // sorted by score, highest first
+	Spectators       []Player      // sorted by name
+	Time             time.Time     // when the server was queried
+}
+
+// Team is a team on the scoreboard.
+type Team struct {
+	Name    string
+	Score   int
+	Players []Player // sorted like in game
+}
+
+// Player is a line on the scoreboard.
+type Player struct {
+	CN        int
+	Name      template.HTML // with color codes rendered
+	Frags     int
+	Deaths    int
+	Flags     int
+	Ping      int
+	KpD       float64
+	Privilege string // "none", "master", "auth" or "admin"
+	Dead      bool
+	IsBot     bool
+}
+
+// NewView prepares snap for rendering.
+func NewView(snap *poll.Snapshot) *View {
+	info := snap.BasicInfo
+	v := &View{
+		Addr:             snap.Addr,
+		Description:      colors.HTML(info.Description),
+		PlainDescription: colors.Strip(info.Description),
+		Map:              info.Map,
+		Mode:             info.GameMode,
+		MasterMode:       info.MasterMode,
+		SecsLeft:         info.SecsLeft,
+		Paused:           info.Paused,
+		Intermission:     info.SecsLeft <= 0,
+		Clients:          info.NumberOfClients,
+		MaxClients:       info.MaxNumberOfClients,
+		Time:             snap.Time,
+	}
+
+	sb := extinfo.NewScoreboard(info, snap.TeamScores, snap.Clients)
+	v.FlagMode, v.TeamMode = sb.FlagMode, sb.TeamMode
+
+	for _, team := range sb.Teams {
+		t := Team{Name: team.Name, Score: team.Score}
+		for _, entry := range team.Players {
+			t.Players = append(t.Players, newPlayer(entry))
+		}
+		v.Teams = append(v.Teams, t)
+	}
+
+	for _, entry := range sb.Spectators {
+		v.Spectators = append(v.Spectators, newPlayer(entry))
+	}
+
+	return v
+}
+
+func newPlayer(entry extinfo.ScoreboardEntry) Player {
+	return Player{
+		CN:        entry.ClientNum,
+		Name:      colors.HTML(entry.Name),
+		Frags:     entry.Frags,
+		Deaths:    entry.Deaths,
+		Flags:     entry.Flags,
+		Ping:      entry.Ping,
+		KpD:       entry.KpD,
+		Privilege: entry.Privilege,
+		Dead:      entry.State == "dead",
+		IsBot:     entry.IsBot,
+	}
+}
+
+// Card writes the status card of snap to w.
+func Card(w io.Writer, snap *poll.Snapshot) error {
+	return templates.ExecuteTemplate(w, "card", NewView(snap))
+}
+
+// Scoreboard writes the scoreboard of snap to w.
+func Scoreboard(w io.Writer, snap *poll.Snapshot) error {
+	return templates.ExecuteTemplate(w, "scoreboard", NewView(snap))
+}
+
+// Page writes a complete HTML document to w, showing the status card and scoreboard of snap, including the default style and the countdown script.
+func Page(w io.Writer, snap *poll.Snapshot) error {
+	return templates.ExecuteTemplate(w, "page", NewView(snap))
+}
+
+// formats seconds as m:ss
+func formatSeconds(seconds int) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	s := strconv.Itoa(seconds % 60)
+	if len(s) < 2 {
+		s = "0" + s
+	}
+	return strconv.Itoa(seconds/60) + ":" + s
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/internal/fakeserver"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+func querySnapshot(t *testing.T, state fakeserver.State) *poll.Snapshot {
+	t.Helper()
+
+	fake, err := fakeserver.Start(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	s, err := extinfo.NewServer(fake.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := poll.Query(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func TestNewView(t *testing.T) {
+	v := NewView(querySnapshot(t, fakeserver.DefaultState()))
+
+	if !v.TeamMode || !v.FlagMode || len(v.Teams) != 2 {
+		t.Fatalf("unexpected view %+v", v)
+	}
+	if good := v.Teams[0]; good.Name != "good" || good.Score != 3 || len(good.Players) != 1 || good.Players[0].Name != "alice" {
+		t.Errorf("unexpected first team %+v", good)
+	}
+	if evil := v.Teams[1]; len(evil.Players) != 2 || evil.Players[0].Name != "bob" || !evil.Players[0].Dead || !evil.Players[1].IsBot {
+		t.Errorf("unexpected second team %+v", evil)
+	}
+	if len(v.Spectators) != 1 || v.Spectators[0].Name != "carol" {
+		t.Errorf("unexpected spectators %+v", v.Spectators)
+	}
+
+	state := fakeserver.DefaultState()
+	state.TeamMode = false
+	state.GameMode = 3
+	v = NewView(querySnapshot(t, state))
+	if v.TeamMode || v.FlagMode || len(v.Teams) != 1 || len(v.Teams[0].Players) != 3 || v.Teams[0].Players[0].Name != "alice" {
+		t.Errorf("unexpected view in non-team mode %+v", v)
+	}
+}
+
+func TestPage(t *testing.T) {
+	state := fakeserver.DefaultState()
+	state.Clients[0].Name = "<script>alert(1)</script>"
+	state.Description = "\f3red \f7server"
+	snap := querySnapshot(t, state)
+
+	b := &bytes.Buffer{}
+	if err := Page(b, snap); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+
+	for _, expected := range []string{
+		`<span class="sauer-time-left" data-secs-left="300">5:00</span>`,
+		`<span class="sauer-team-name">good</span> <span class="sauer-team-score">3</span>`,
+		`&lt;script&gt;alert(1)&lt;/script&gt;`,
+		`<title>red server – ` + state.Map + `</title>`,
+		`<span class="sauer-privilege" title="master">master</span>`,
+		`<tr class="sauer-player sauer-privilege-none sauer-bot">`,
+		`spectators: <span class="sauer-spectator sauer-privilege-none">carol</span>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("page does not contain %s:\n%s", expected, page)
+		}
+	}
+	if strings.Contains(page, "<script>alert") {
+		t.Error("player name not escaped")
+	}
+}
@@ -0,0 +1,158 @@
+package extinfo
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultGameLength is how long a game lasts on servers that don't change the time limit.
+const DefaultGameLength = 10 * time.Minute
+
+// Scoreboard is the state of a game, arranged like the in-game scoreboard.
+type Scoreboard struct {
+	GameMode   string            `json:"gameMode"`   //
+	TeamMode   bool              `json:"teamMode"`   // whether Teams has one entry per team, or a single one without a name
+	FlagMode   bool              `json:"flagMode"`   // whether players are ranked by flags first
+	Teams      []ScoreboardTeam  `json:"teams"`      // sorted by score, highest first, then by name
+	Spectators []ScoreboardEntry `json:"spectators"` // sorted by name
+}
+
+// ScoreboardTeam is a team and its players.
+type ScoreboardTeam struct {
+	Name    string            `json:"name"`    // "" outside of team modes
+	Score   int               `json:"score"`   // the team's score as reported by the server; 0 outside of team modes
+	Players []ScoreboardEntry `json:"players"` // sorted like in game: by flags (in flag modes), then frags, then name
+}
+
+// ScoreboardEntry is a client's line on the scoreboard, including values derived from the client's info.
+type ScoreboardEntry struct {
+	ClientInfo
+	KpD            float64 `json:"kpd"`            // frags per death; frags if the client never died
+	FragsPerMinute float64 `json:"fragsPerMinute"` // frags per minute of the game played so far
+	NetScore       int     `json:"netScore"`       // frags minus deaths
+}
+
+// ScoreboardOption changes how NewScoreboard() computes derived values.
+type ScoreboardOption func(*scoreboardOptions)
+
+type scoreboardOptions struct {
+	gameLength time.Duration
+}
+
+// GameLength sets how long a game lasts on the server, to compute frags per minute from the time left. Defaults to DefaultGameLength.
+func GameLength(length time.Duration) ScoreboardOption {
+	return func(o *scoreboardOptions) {
+		o.gameLength = length
+	}
+}
+
+// NewScoreboard arranges allClientInfo (as returned by GetAllClientInfo()) like the game does. teamScores may be nil, e.g. when no team mode is being played; in team modes, teams without a score are still listed when there are players in them.
+func NewScoreboard(basicInfo BasicInfo, teamScores *TeamScores, allClientInfo map[int]ClientInfo, options ...ScoreboardOption) Scoreboard {
+	opts := scoreboardOptions{gameLength: DefaultGameLength}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	sb := Scoreboard{
+		GameMode:   basicInfo.GameMode,
+		TeamMode:   IsTeamMode(basicInfo.GameMode),
+		FlagMode:   IsFlagMode(basicInfo.GameMode),
+		Teams:      []ScoreboardTeam{},
+		Spectators: []ScoreboardEntry{},
+	}
+
+	minutesPlayed := (opts.gameLength - time.Duration(basicInfo.SecsLeft)*time.Second).Minutes()
+	if basicInfo.SecsLeft <= 0 {
+		minutesPlayed = opts.gameLength.Minutes()
+	}
+
+	teams := map[string]*ScoreboardTeam{}
+	team := func(name string) *ScoreboardTeam {
+		if teams[name] == nil {
+			teams[name] = &ScoreboardTeam{Name: name, Players: []ScoreboardEntry{}}
+		}
+		return teams[name]
+	}
+
+	if sb.TeamMode && teamScores != nil {
+		for _, score := range teamScores.Scores {
+			team(score.Name).Score = score.Score
+		}
+	}
+	if !sb.TeamMode {
+		team("")
+	}
+
+	for _, clientInfo := range allClientInfo {
+		entry := newScoreboardEntry(clientInfo, minutesPlayed)
+
+		if clientInfo.State == "spectator" {
+			sb.Spectators = append(sb.Spectators, entry)
+			continue
+		}
+
+		name := ""
+		if sb.TeamMode {
+			name = clientInfo.Team
+		}
+		t := team(name)
+		t.Players = append(t.Players, entry)
+	}
+
+	for _, t := range teams {
+		sortEntries(t.Players, sb.FlagMode)
+		sb.Teams = append(sb.Teams, *t)
+	}
+	sort.Slice(sb.Teams, func(i, j int) bool {
+		if sb.Teams[i].Score != sb.Teams[j].Score {
+			return sb.Teams[i].Score > sb.Teams[j].Score
+		}
+		return sb.Teams[i].Name < sb.Teams[j].Name
+	})
+
+	sort.Slice(sb.Spectators, func(i, j int) bool { return sb.Spectators[i].Name < sb.Spectators[j].Name })
+
+	return sb
+}
+
+func newScoreboardEntry(clientInfo ClientInfo, minutesPlayed float64) ScoreboardEntry {
+	entry := ScoreboardEntry{
+		ClientInfo: clientInfo,
+		KpD:        float64(clientInfo.Frags),
+		NetScore:   clientInfo.Frags - clientInfo.Deaths,
+	}
+
+	if clientInfo.Deaths > 0 {
+		entry.KpD = float64(clientInfo.Frags) / float64(clientInfo.Deaths)
+	}
+
+	if minutesPlayed > 0 {
+		entry.FragsPerMinute = float64(clientInfo.Frags) / minutesPlayed
+	}
+
+	return entry
+}
+
+// sorts entries like the game does: by flags (in flag modes), then frags, then name
+func sortEntries(entries []ScoreboardEntry, flagMode bool) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if flagMode && a.Flags != b.Flags {
+			return a.Flags > b.Flags
+		}
+		if a.Frags != b.Frags {
+			return a.Frags > b.Frags
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Players returns the entries of all players (i.e. not spectators) across all teams, sorted like in game.
+func (sb Scoreboard) Players() []ScoreboardEntry {
+	players := []ScoreboardEntry{}
+	for _, t := range sb.Teams {
+		players = append(players, t.Players...)
+	}
+	sortEntries(players, sb.FlagMode)
+	return players
+}
@@ -0,0 +1,90 @@
+package extinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewScoreboard(t *testing.T) {
+	basicInfo, err := srv.GetBasicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	teamScores, err := srv.GetTeamScores()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allClientInfo, err := srv.GetAllClientInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sb := NewScoreboard(basicInfo, &teamScores, allClientInfo)
+
+	if !sb.TeamMode || !sb.FlagMode || len(sb.Teams) != 2 {
+		t.Fatalf("unexpected scoreboard %+v", sb)
+	}
+	if good := sb.Teams[0]; good.Name != "good" || good.Score != 3 || len(good.Players) != 1 {
+		t.Errorf("unexpected first team %+v", good)
+	}
+	if evil := sb.Teams[1]; len(evil.Players) != 2 || evil.Players[0].Name != "bob" || evil.Players[1].Name != "bot" {
+		t.Errorf("unexpected second team %+v", evil)
+	}
+	if len(sb.Spectators) != 1 || sb.Spectators[0].Name != "carol" {
+		t.Errorf("unexpected spectators %+v", sb.Spectators)
+	}
+
+	// 20 frags and 10 deaths, with 5 of 10 minutes played
+	alice := sb.Teams[0].Players[0]
+	if alice.KpD != 2 || alice.NetScore != 10 || alice.FragsPerMinute != 4 {
+		t.Errorf("unexpected derived values %+v", alice)
+	}
+
+	sb = NewScoreboard(basicInfo, &teamScores, allClientInfo, GameLength(15*time.Minute))
+	if alice = sb.Teams[0].Players[0]; alice.FragsPerMinute != 2 {
+		t.Errorf("expected 2 frags per minute with 10 of 15 minutes played, got %v", alice.FragsPerMinute)
+	}
+}
+
+func TestScoreboardSortOrder(t *testing.T) {
+	client := func(cn int, name string, frags, flags int) ClientInfo {
+		c := ClientInfo{State: "alive"}
+		c.ClientNum, c.Name, c.Team, c.Frags, c.Flags = cn, name, "good", frags, flags
+		return c
+	}
+	allClientInfo := map[int]ClientInfo{
+		0: client(0, "a", 10, 0),
+		1: client(1, "b", 5, 2),
+		2: client(2, "c", 10, 1),
+		3: client(3, "d", 5, 2),
+	}
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"insta ctf", "bdca"},
+		{"collect", "bdca"},
+		{"efficiency collect", "bdca"},
+		{"instagib", "acbd"},
+	}
+
+	for _, test := range tests {
+		basicInfo := BasicInfo{GameMode: test.mode}
+
+		sb := NewScoreboard(basicInfo, nil, allClientInfo)
+		order := ""
+		for _, entry := range sb.Players() {
+			order += entry.Name
+		}
+		if order != test.expected {
+			t.Errorf("%s: expected order %s, got %s", test.mode, test.expected, order)
+		}
+	}
+
+	// team mode without team scores
+	sb := NewScoreboard(BasicInfo{GameMode: "insta ctf"}, nil, allClientInfo)
+	if len(sb.Teams) != 1 || sb.Teams[0].Name != "good" || len(sb.Teams[0].Players) != 4 {
+		t.Errorf("unexpected teams %+v", sb.Teams)
+	}
+}
@@ -4,16 +4,29 @@ import "github.com/sauerbraten/cubecode"
 
 // GetServerMod returns the name of the mod in use at this server.
 func (s *Server) GetServerMod() (serverMod string, err error) {
-	var response *cubecode.Packet
-	uptimeRequest := buildRequest(InfoTypeExtended, ExtInfoTypeUptime, 0)
-	modRequest := append(uptimeRequest, 0x01)
-	response, err = s.queryServer(modRequest)
+	uptime, serverMod, err := s.queryServerMod()
 	if err != nil {
 		return
 	}
 
-	// read & discard uptime
-	_, err = response.ReadInt()
+	s.observeUptime(uptime)
+	return
+}
+
+// queries the server's uptime and mod
+func (s *Server) queryServerMod() (uptime int, serverMod string, err error) {
+	// a non-zero argument to the uptime command makes mods append their ID
+	err = s.queryServer(buildExtendedRequest(ExtInfoTypeUptime, 1), func(response *cubecode.Packet) (err error) {
+		uptime, serverMod, err = parseServerMod(response)
+		return
+	})
+
+	return
+}
+
+// parses the response to an uptime request with a non-zero argument, starting after the version
+func parseServerMod(response *cubecode.Packet) (uptime int, serverMod string, err error) {
+	uptime, err = response.ReadInt()
 	if err != nil {
 		return
 	}
@@ -23,7 +36,7 @@ func (s *Server) GetServerMod() (serverMod string, err error) {
 
 	// if there is none, it's not a detectable mod (probably vanilla), so we will return ""
 	if err == cubecode.ErrBufferTooShort {
-		err = nil
+		return uptime, "", nil
 	} else if err == nil {
 		serverMod = getServerModName(mod)
 	}
@@ -0,0 +1,91 @@
+// Package stats keeps statistics about the games played on Sauerbraten servers in an SQLite database, fed from poll results.
+//
+// A game starts when a server is first seen on a map or switches to a new one, and ends at intermission. For every game, the final team scores and one line per player (frags, deaths, flags, teamkills, accuracy) are kept. Within a game, a line belongs to a client number and name, so players using the same name at the same time get a line each; across games, players are identified by name, since that's all a server reports.
+package stats
+
+import (
+	"database/sql"
+	"sync"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS servers (
+	id          INTEGER PRIMARY KEY,
+	addr        TEXT    NOT NULL UNIQUE,
+	description TEXT    NOT NULL DEFAULT '',
+	mod         TEXT    NOT NULL DEFAULT '',
+	first_seen  INTEGER NOT NULL,
+	last_seen   INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS games (
+	id         INTEGER PRIMARY KEY,
+	server_id  INTEGER NOT NULL REFERENCES servers (id),
+	map        TEXT    NOT NULL,
+	mode       TEXT    NOT NULL,
+	started_at INTEGER NOT NULL,
+	ended_at   INTEGER,
+	secs_left  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS games_server ON games (server_id, started_at);
+
+CREATE TABLE IF NOT EXISTS game_teams (
+	game_id INTEGER NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	team    TEXT    NOT NULL,
+	score   INTEGER NOT NULL,
+	PRIMARY KEY (game_id, team)
+);
+
+CREATE TABLE IF NOT EXISTS game_players (
+	game_id    INTEGER NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	cn         INTEGER NOT NULL,
+	name       TEXT    NOT NULL,
+	team       TEXT    NOT NULL,
+	frags      INTEGER NOT NULL,
+	deaths     INTEGER NOT NULL,
+	flags      INTEGER NOT NULL,
+	teamkills  INTEGER NOT NULL,
+	accuracy   INTEGER NOT NULL,
+	is_bot     INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (game_id, cn, name)
+);
+CREATE INDEX IF NOT EXISTS game_players_name ON game_players (name);
+`
+
+// DB is a statistics database. It is safe for concurrent use.
+type DB struct {
+	db *sql.DB
+
+	mutex sync.Mutex
+	games map[string]*currentGame // the game currently played on each server, keyed by address
+}
+
+// Open opens the database at path, creating it if needed. Use ":memory:" for a database that is discarded when closed.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows only one writer anyway, and an in-memory database only exists in the connection that created it
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{
+		db:    db,
+		games: map[string]*currentGame{},
+	}, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
@@ -0,0 +1,327 @@
+package stats
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNoSuchGame is returned by Game() when there is no game with the given ID.
+var ErrNoSuchGame = errors.New("stats: no such game")
+
+// Game is a game played on a server.
+type Game struct {
+	ID      int64        `json:"id"`
+	Server  string       `json:"server"`  // the server's address as host:port
+	Map     string       `json:"map"`     //
+	Mode    string       `json:"mode"`    // game mode, e.g. "insta ctf"
+	Start   time.Time    `json:"start"`   // when the game was first seen
+	End     *time.Time   `json:"end"`     // when intermission (or, if it was skipped, the next game) was first seen; nil while the game is being played
+	Teams   []TeamResult `json:"teams"`   // the team scores, highest first; empty outside of team modes
+	Players []PlayerLine `json:"players"` // the players' lines, best first; only filled in by Game()
+}
+
+// TeamResult is the score of a team in a game.
+type TeamResult struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+// PlayerLine is how a player did in a game. Players using the same name at the same time have a line each.
+type PlayerLine struct {
+	ClientNum int    `json:"clientNum"`
+	Name      string `json:"name"`
+	Team      string `json:"team"`
+	Frags     int    `json:"frags"`
+	Deaths    int    `json:"deaths"`
+	Flags     int    `json:"flags"`
+	Teamkills int    `json:"teamkills"`
+	Accuracy  int    `json:"accuracy"` // in percent
+	IsBot     bool   `json:"isBot"`
+}
+
+// PlayerGame is a player's line in a game, as returned by PlayerHistory().
+type PlayerGame struct {
+	Game Game       `json:"game"`
+	Line PlayerLine `json:"line"`
+}
+
+// PlayerStats are a player's totals over a number of games.
+type PlayerStats struct {
+	Name      string  `json:"name"`
+	Games     int     `json:"games"`
+	Frags     int     `json:"frags"`
+	Deaths    int     `json:"deaths"`
+	Flags     int     `json:"flags"`
+	Teamkills int     `json:"teamkills"`
+	Accuracy  float64 `json:"accuracy"` // average over all games, in percent
+	KpD       float64 `json:"kpd"`      // frags per death; frags if the player never died
+}
+
+// Order is what a leaderboard is sorted by.
+type Order string
+
+// Leaderboard orders
+const (
+	ByFrags    Order = "frags"
+	ByKpD      Order = "kpd"
+	ByFlags    Order = "flags"
+	ByGames    Order = "games"
+	ByAccuracy Order = "accuracy"
+)
+
+// LeaderboardOptions select the games a leaderboard is computed from and how it is sorted.
+type LeaderboardOptions struct {
+	Since    time.Time // only count games started at or after this time; zero for all games
+	Server   string    // only count games played on the server with this address (host:port); "" for all servers
+	Mode     string    // only count games of this mode, e.g. "insta ctf"; "" for all modes
+	OrderBy  Order     // defaults to ByFrags
+	MinGames int       // leave out players with fewer games
+	Limit    int       // number of players to return; defaults to 10
+}
+
+// Leaderboard returns the best players, as selected and ordered by options. Bots are left out.
+func (db *DB) Leaderboard(options LeaderboardOptions) ([]PlayerStats, error) {
+	where, args := []string{"NOT p.is_bot"}, []interface{}{}
+	if !options.Since.IsZero() {
+		where = append(where, "g.started_at >= ?")
+		args = append(args, options.Since.Unix())
+	}
+	if options.Server != "" {
+		where = append(where, "s.addr = ?")
+		args = append(args, options.Server)
+	}
+	if options.Mode != "" {
+		where = append(where, "g.mode = ?")
+		args = append(args, options.Mode)
+	}
+
+	orderBy := "frags DESC"
+	switch options.OrderBy {
+	case ByKpD:
+		orderBy = "kpd DESC"
+	case ByFlags:
+		orderBy = "flags DESC"
+	case ByGames:
+		orderBy = "games DESC"
+	case ByAccuracy:
+		orderBy = "accuracy DESC"
+	}
+
+	limit := options.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	args = append(args, options.MinGames, limit)
+
+	rows, err := db.db.Query(`
+		SELECT p.name, COUNT(*) AS games, SUM(p.frags) AS frags, SUM(p.deaths), SUM(p.flags) AS flags, SUM(p.teamkills),
+			AVG(p.accuracy) AS accuracy, CAST(SUM(p.frags) AS REAL) / MAX(SUM(p.deaths), 1) AS kpd
+		FROM (
+			SELECT game_id, name, MAX(frags) AS frags, MAX(deaths) AS deaths, MAX(flags) AS flags, MAX(teamkills) AS teamkills, MAX(accuracy) AS accuracy, MAX(is_bot) AS is_bot
+			FROM `+currentLines+`
+			GROUP BY game_id, name
+		) p
+		JOIN games g ON g.id = p.game_id
+		JOIN servers s ON s.id = g.server_id
+		WHERE `+strings.Join(where, " AND ")+`
+		GROUP BY p.name
+		HAVING games >= ?
+		ORDER BY `+orderBy+`, p.name
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaderboard := []PlayerStats{}
+	for rows.Next() {
+		var stats PlayerStats
+		err = rows.Scan(&stats.Name, &stats.Games, &stats.Frags, &stats.Deaths, &stats.Flags, &stats.Teamkills, &stats.Accuracy, &stats.KpD)
+		if err != nil {
+			return nil, err
+		}
+		leaderboard = append(leaderboard, stats)
+	}
+
+	return leaderboard, rows.Err()
+}
+
+// PlayerHistory returns the lines of the player with the given name in their most recent games, newest first. A limit of 0 or less returns all games.
+func (db *DB) PlayerHistory(name string, limit int) ([]PlayerGame, error) {
+	rows, err := db.db.Query(`
+		SELECT `+gameColumns+`, p.cn, p.name, p.team, p.frags, p.deaths, p.flags, p.teamkills, p.accuracy, p.is_bot
+		FROM `+currentLines+` p
+		JOIN games g ON g.id = p.game_id
+		JOIN servers s ON s.id = g.server_id
+		WHERE p.name = ?
+		ORDER BY g.started_at DESC, g.id DESC
+		LIMIT ?`, name, sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []PlayerGame{}
+	for rows.Next() {
+		var pg PlayerGame
+		var start int64
+		var end sql.NullInt64
+		err = rows.Scan(&pg.Game.ID, &pg.Game.Server, &pg.Game.Map, &pg.Game.Mode, &start, &end,
+			&pg.Line.ClientNum, &pg.Line.Name, &pg.Line.Team, &pg.Line.Frags, &pg.Line.Deaths, &pg.Line.Flags, &pg.Line.Teamkills, &pg.Line.Accuracy, &pg.Line.IsBot)
+		if err != nil {
+			return nil, err
+		}
+		setTimes(&pg.Game, start, end)
+		history = append(history, pg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range history {
+		history[i].Game.Teams, err = db.teams(history[i].Game.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return history, nil
+}
+
+// RecentGames returns the most recent games played on the server with the given address (host:port), or on all servers if server is "", newest first. A limit of 0 or less returns all games.
+func (db *DB) RecentGames(server string, limit int) ([]Game, error) {
+	rows, err := db.db.Query(`
+		SELECT `+gameColumns+`
+		FROM games g
+		JOIN servers s ON s.id = g.server_id
+		WHERE ? = '' OR s.addr = ?
+		ORDER BY g.started_at DESC, g.id DESC
+		LIMIT ?`, server, server, sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []Game{}
+	for rows.Next() {
+		game, err := scanGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range games {
+		games[i].Teams, err = db.teams(games[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return games, nil
+}
+
+// Game returns the game with the given ID, including the players' lines.
+func (db *DB) Game(id int64) (game Game, err error) {
+	game, err = scanGame(db.db.QueryRow(`
+		SELECT `+gameColumns+`
+		FROM games g
+		JOIN servers s ON s.id = g.server_id
+		WHERE g.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNoSuchGame
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	game.Teams, err = db.teams(id)
+	if err != nil {
+		return
+	}
+
+	rows, err := db.db.Query(`
+		SELECT cn, name, team, frags, deaths, flags, teamkills, accuracy, is_bot
+		FROM `+currentLines+`
+		WHERE game_id = ?
+		ORDER BY flags DESC, frags DESC, name, cn`, id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	game.Players = []PlayerLine{}
+	for rows.Next() {
+		var line PlayerLine
+		err = rows.Scan(&line.ClientNum, &line.Name, &line.Team, &line.Frags, &line.Deaths, &line.Flags, &line.Teamkills, &line.Accuracy, &line.IsBot)
+		if err != nil {
+			return
+		}
+		game.Players = append(game.Players, line)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// returns limit as SQLite expects it, where a negative limit means no limit
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+// the columns scanGame() expects, for queries joining games g and servers s
+const gameColumns = "g.id, s.addr, g.map, g.mode, g.started_at, g.ended_at"
+
+// the lines updated when their player's name was last seen in the game, which leaves out the old lines of players who reconnected, since the game restores their score under the new client number
+const currentLines = `(
+	SELECT * FROM game_players l
+	WHERE l.updated_at = (SELECT MAX(updated_at) FROM game_players WHERE game_id = l.game_id AND name = l.name)
+)`
+
+func scanGame(row interface{ Scan(...interface{}) error }) (game Game, err error) {
+	var start int64
+	var end sql.NullInt64
+	err = row.Scan(&game.ID, &game.Server, &game.Map, &game.Mode, &start, &end)
+	if err != nil {
+		return
+	}
+	setTimes(&game, start, end)
+	return
+}
+
+func setTimes(game *Game, start int64, end sql.NullInt64) {
+	game.Start = time.Unix(start, 0)
+	if end.Valid {
+		t := time.Unix(end.Int64, 0)
+		game.End = &t
+	}
+}
+
+// returns the team scores of the game with the given ID, highest first
+func (db *DB) teams(gameID int64) ([]TeamResult, error) {
+	rows, err := db.db.Query("SELECT team, score FROM game_teams WHERE game_id = ? ORDER BY score DESC, team", gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []TeamResult{}
+	for rows.Next() {
+		var team TeamResult
+		if err := rows.Scan(&team.Name, &team.Score); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	return teams, rows.Err()
+}
@@ -0,0 +1,212 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// the game the last snapshot of a server showed
+type currentGame struct {
+	id       int64
+	mapName  string
+	mode     string
+	secsLeft int
+	ended    bool
+	lastSeen time.Time
+}
+
+// reports whether snap shows a different game than g
+func (g *currentGame) isOver(snap *poll.Snapshot) bool {
+	info := snap.BasicInfo
+	// the time left only goes up when a new game started
+	return g.mapName != info.Map || g.mode != info.GameMode || info.SecsLeft > g.secsLeft
+}
+
+// Record updates the statistics of the game being played on the snapshot's server. Snapshots of a server have to be recorded in the order they were taken.
+func (db *DB) Record(snap *poll.Snapshot) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	game := db.games[snap.Addr]
+	if game == nil {
+		// continue where the last run left off
+		game, err = lastGame(tx, snap.Addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	serverID, err := upsertServer(tx, snap)
+	if err != nil {
+		return err
+	}
+
+	if game != nil && game.isOver(snap) {
+		// the game was ended early, e.g. by a map vote, so intermission was never seen
+		if !game.ended {
+			err = endGame(tx, game, game.lastSeen)
+			if err != nil {
+				return err
+			}
+		}
+		game = nil
+	}
+
+	if game == nil {
+		game, err = startGame(tx, serverID, snap)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !game.ended {
+		err = updateScores(tx, game.id, snap)
+		if err != nil {
+			return err
+		}
+
+		if snap.BasicInfo.SecsLeft <= 0 {
+			err = endGame(tx, game, snap.Time)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	game.secsLeft = snap.BasicInfo.SecsLeft
+	game.lastSeen = snap.Time
+	_, err = tx.Exec("UPDATE games SET secs_left = ? WHERE id = ?", game.secsLeft, game.id)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	db.games[snap.Addr] = game
+	return nil
+}
+
+// Follow records the snapshot of every successful poll received, until updates is closed, ctx is cancelled or recording fails. updates usually comes from (*poll.Poller).Subscribe(), using poll.Lossless(), since a missed update might be the one showing a game's end.
+func (db *DB) Follow(ctx context.Context, updates <-chan poll.Update) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if update.State.LastError != "" || update.State.Snapshot == nil {
+				continue
+			}
+			if err := db.Record(update.State.Snapshot); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// returns the most recent game recorded for the server at addr, or nil if there is none
+func lastGame(tx *sql.Tx, addr string) (*currentGame, error) {
+	game := &currentGame{}
+	var lastSeen int64
+	err := tx.QueryRow(`
+		SELECT games.id, games.map, games.mode, games.secs_left, games.ended_at IS NOT NULL, servers.last_seen
+		FROM games JOIN servers ON servers.id = games.server_id
+		WHERE servers.addr = ?
+		ORDER BY games.started_at DESC, games.id DESC
+		LIMIT 1`, addr).Scan(&game.id, &game.mapName, &game.mode, &game.secsLeft, &game.ended, &lastSeen)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	game.lastSeen = time.Unix(lastSeen, 0)
+	return game, nil
+}
+
+// inserts or updates the server of snap and returns its ID
+func upsertServer(tx *sql.Tx, snap *poll.Snapshot) (id int64, err error) {
+	err = tx.QueryRow(`
+		INSERT INTO servers (addr, description, mod, first_seen, last_seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (addr) DO UPDATE SET description = excluded.description, mod = excluded.mod, last_seen = excluded.last_seen
+		RETURNING id`, snap.Addr, snap.Description(), snap.Mod, snap.Time.Unix(), snap.Time.Unix()).Scan(&id)
+	return
+}
+
+func startGame(tx *sql.Tx, serverID int64, snap *poll.Snapshot) (*currentGame, error) {
+	info := snap.BasicInfo
+	game := &currentGame{
+		mapName:  info.Map,
+		mode:     info.GameMode,
+		secsLeft: info.SecsLeft,
+	}
+
+	result, err := tx.Exec("INSERT INTO games (server_id, map, mode, started_at, secs_left) VALUES (?, ?, ?, ?, ?)",
+		serverID, info.Map, info.GameMode, snap.Time.Unix(), info.SecsLeft)
+	if err != nil {
+		return nil, err
+	}
+
+	game.id, err = result.LastInsertId()
+	return game, err
+}
+
+// marks game as ended at the given time; games nobody played in are deleted
+func endGame(tx *sql.Tx, game *currentGame, at time.Time) error {
+	game.ended = true
+
+	_, err := tx.Exec("UPDATE games SET ended_at = ? WHERE id = ?", at.Unix(), game.id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM games WHERE id = ? AND NOT EXISTS (SELECT 1 FROM game_players WHERE game_id = ?)", game.id, game.id)
+	return err
+}
+
+// stores the team scores and the lines of everyone playing, one per client number and name; spectators keep the line they had when they stopped playing, and so does a player who reconnected, until their new line catches up
+func updateScores(tx *sql.Tx, gameID int64, snap *poll.Snapshot) error {
+	if snap.TeamScores != nil {
+		for _, team := range snap.TeamScores.Scores {
+			_, err := tx.Exec(`
+				INSERT INTO game_teams (game_id, team, score) VALUES (?, ?, ?)
+				ON CONFLICT (game_id, team) DO UPDATE SET score = excluded.score`, gameID, team.Name, team.Score)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range snap.Clients {
+		if c.State == "spectator" {
+			continue
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO game_players (game_id, cn, name, team, frags, deaths, flags, teamkills, accuracy, is_bot, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (game_id, cn, name) DO UPDATE SET
+				team = excluded.team, frags = excluded.frags, deaths = excluded.deaths, flags = excluded.flags,
+				teamkills = excluded.teamkills, accuracy = excluded.accuracy, is_bot = excluded.is_bot, updated_at = excluded.updated_at`,
+			gameID, c.ClientNum, c.Name, c.Team, c.Frags, c.Deaths, c.Flags, c.Teamkills, c.Accuracy, c.IsBot, snap.Time.Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo"
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+const addr = "127.0.0.1:28785"
+
+type player struct {
+	name   string
+	team   string
+	frags  int
+	deaths int
+	flags  int
+	state  string
+}
+
+var start = time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+func snapshot(minute int, mapName string, secsLeft int, scores map[string]int, players ...player) *poll.Snapshot {
+	snap := &poll.Snapshot{
+		Time:    start.Add(time.Duration(minute) * time.Minute),
+		Addr:    addr,
+		Clients: map[int]extinfo.ClientInfo{},
+	}
+	snap.BasicInfo.Map = mapName
+	snap.BasicInfo.GameMode = "insta ctf"
+	snap.BasicInfo.SecsLeft = secsLeft
+
+	if scores != nil {
+		snap.TeamScores = &extinfo.TeamScores{TeamScoresRaw: extinfo.TeamScoresRaw{Scores: map[string]extinfo.TeamScore{}}}
+		for name, score := range scores {
+			snap.TeamScores.Scores[name] = extinfo.TeamScore{Name: name, Score: score}
+		}
+	}
+
+	for cn, p := range players {
+		c := extinfo.ClientInfo{State: p.state}
+		if c.State == "" {
+			c.State = "alive"
+		}
+		c.ClientNum, c.Name, c.Team, c.Frags, c.Deaths, c.Flags = cn, p.name, p.team, p.frags, p.deaths, p.flags
+		snap.Clients[cn] = c
+	}
+	return snap
+}
+
+func TestStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { db.Close() }()
+
+	carol := player{name: "carol", state: "spectator"}
+
+	record := func(snaps ...*poll.Snapshot) {
+		t.Helper()
+		for _, snap := range snaps {
+			if err := db.Record(snap); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// a complete game on forge
+	record(
+		snapshot(0, "forge", 600, map[string]int{"good": 0, "evil": 0}, player{"alice", "good", 5, 2, 0, ""}, player{"bob", "evil", 3, 5, 0, ""}, carol),
+		snapshot(5, "forge", 300, map[string]int{"good": 1, "evil": 0}, player{"alice", "good", 10, 4, 1, ""}, player{"bob", "evil", 6, 10, 0, ""}, carol),
+		snapshot(10, "forge", 0, map[string]int{"good": 2, "evil": 1}, player{"alice", "good", 12, 5, 2, ""}, player{"bob", "evil", 8, 12, 1, ""}, carol),
+		snapshot(10, "forge", 0, map[string]int{"good": 2, "evil": 1}, player{"alice", "good", 12, 5, 2, ""}, player{"bob", "evil", 8, 12, 1, ""}, carol),
+	)
+
+	// a game on reissen, interrupted by a restart of the recorder
+	record(snapshot(11, "reissen", 600, nil, player{"alice", "good", 1, 0, 0, ""}))
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(snapshot(12, "reissen", 540, nil, player{"alice", "good", 3, 1, 0, ""}))
+
+	// a map vote ends the reissen game early, and nobody plays on ot
+	record(
+		snapshot(13, "ot", 600, nil, carol),
+		snapshot(23, "ot", 0, nil, carol),
+	)
+
+	games, err := db.RecentGames("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("expected 2 games, got %+v", games)
+	}
+	reissen, forge := games[0], games[1]
+	if reissen.Map != "reissen" || reissen.End == nil || !reissen.End.Equal(start.Add(12*time.Minute)) {
+		t.Errorf("unexpected game %+v", reissen)
+	}
+	if forge.Map != "forge" || forge.End == nil || !forge.End.Equal(start.Add(10*time.Minute)) || len(forge.Teams) != 2 || forge.Teams[0] != (TeamResult{"good", 2}) {
+		t.Errorf("unexpected game %+v", forge)
+	}
+
+	game, err := db.Game(forge.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Players) != 2 || game.Players[0].Name != "alice" || game.Players[0].Frags != 12 || game.Players[1].Deaths != 12 {
+		t.Errorf("unexpected players %+v", game.Players)
+	}
+	if _, err = db.Game(1000); err != ErrNoSuchGame {
+		t.Errorf("expected ErrNoSuchGame, got %v", err)
+	}
+
+	leaderboard, err := db.Leaderboard(LeaderboardOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaderboard) != 2 || leaderboard[0].Name != "alice" || leaderboard[0].Games != 2 || leaderboard[0].Frags != 15 || leaderboard[0].KpD != 2.5 {
+		t.Errorf("unexpected leaderboard %+v", leaderboard)
+	}
+
+	leaderboard, err = db.Leaderboard(LeaderboardOptions{MinGames: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaderboard) != 1 {
+		t.Errorf("expected only alice with 2 games, got %+v", leaderboard)
+	}
+
+	history, err := db.PlayerHistory("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Game.Map != "reissen" || history[0].Line.Frags != 3 || history[1].Line.Flags != 2 {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+func TestSameName(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, snap := range []*poll.Snapshot{
+		snapshot(0, "forge", 600, nil, player{"unnamed", "good", 1, 0, 0, ""}, player{"unnamed", "evil", 0, 1, 0, ""}),
+		snapshot(10, "forge", 0, nil, player{"unnamed", "good", 7, 2, 0, ""}, player{"unnamed", "evil", 2, 7, 0, ""}),
+	} {
+		if err := db.Record(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	games, err := db.RecentGames("", 0)
+	if err != nil || len(games) != 1 {
+		t.Fatalf("expected 1 game, got %+v (%v)", games, err)
+	}
+	game, err := db.Game(games[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Players) != 2 || game.Players[0].ClientNum != 0 || game.Players[0].Frags != 7 || game.Players[1].ClientNum != 1 || game.Players[1].Frags != 2 {
+		t.Errorf("unexpected players %+v", game.Players)
+	}
+
+	leaderboard, err := db.Leaderboard(LeaderboardOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// players are told apart by name across games, so the leaderboard counts one line per game and name
+	if len(leaderboard) != 1 || leaderboard[0].Games != 1 || leaderboard[0].Frags != 7 || leaderboard[0].Deaths != 7 {
+		t.Errorf("unexpected leaderboard %+v", leaderboard)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// alice reconnects as cn 2, and the game gives her score back
+	reconnected := func(snap *poll.Snapshot) *poll.Snapshot {
+		alice := snap.Clients[0]
+		alice.ClientNum = 2
+		delete(snap.Clients, 0)
+		snap.Clients[2] = alice
+		return snap
+	}
+	bob := player{"bob", "evil", 2, 3, 0, ""}
+	for _, snap := range []*poll.Snapshot{
+		snapshot(0, "forge", 600, nil, player{"alice", "good", 4, 1, 1, ""}, bob),
+		reconnected(snapshot(5, "forge", 300, nil, player{"alice", "good", 4, 1, 1, ""}, bob)),
+		reconnected(snapshot(10, "forge", 0, nil, player{"alice", "good", 9, 2, 2, ""}, bob)),
+	} {
+		if err := db.Record(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	games, err := db.RecentGames("", 0)
+	if err != nil || len(games) != 1 {
+		t.Fatalf("expected 1 game, got %+v (%v)", games, err)
+	}
+	game, err := db.Game(games[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Players) != 2 || game.Players[0].Name != "alice" || game.Players[0].ClientNum != 2 || game.Players[0].Frags != 9 {
+		t.Errorf("unexpected players %+v", game.Players)
+	}
+
+	leaderboard, err := db.Leaderboard(LeaderboardOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaderboard) != 2 || leaderboard[0].Name != "alice" || leaderboard[0].Games != 1 || leaderboard[0].Frags != 9 || leaderboard[0].Flags != 2 {
+		t.Errorf("unexpected leaderboard %+v", leaderboard)
+	}
+
+	history, err := db.PlayerHistory("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Line.Frags != 9 {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
@@ -1,5 +1,12 @@
 package extinfo
 
+import (
+	"errors"
+	"strconv"
+
+	"github.com/sauerbraten/cubecode"
+)
+
 // TeamScore contains the name of the team and the score, i.e. flags scored in flag modes / points gained for holding bases in capture modes / frags achieved in DM modes / skulls collected
 type TeamScore struct {
 	Name  string `json:"name"`  // name of the team, e.g. "good"
@@ -9,9 +16,10 @@ type TeamScore struct {
 
 // TeamScoresRaw contains the game mode as raw int, the seconds left in the game, and a slice of TeamScores
 type TeamScoresRaw struct {
-	GameMode int                  `json:"gameMode"` // current game mode
-	SecsLeft int                  `json:"secsLeft"` // the time left until intermission in seconds
-	Scores   map[string]TeamScore `json:"scores"`   // a team score for each team, mapped to the team's name
+	GameMode  int                  `json:"gameMode"`            // current game mode
+	SecsLeft  int                  `json:"secsLeft"`            // the time left until intermission in seconds
+	Scores    map[string]TeamScore `json:"scores"`              // a team score for each team, mapped to the team's name
+	Extension Extension            `json:"extension,omitempty"` // mod-specific data following the standard fields, see RegisterExtensionParsers()
 }
 
 // TeamScores contains the game mode as human readable string, the seconds left in the game, and a slice of TeamScores
@@ -20,14 +28,17 @@ type TeamScores struct {
 	GameMode string `json:"gameMode"` // current game mode
 }
 
-// GetTeamScoresRaw queries a Sauerbraten server at addr on port for the teams' names and scores and returns the raw response and/or an error in case something went wrong or the server is not running a team mode.
+// GetTeamScoresRaw queries a Sauerbraten server at addr on port for the teams' names and scores and returns the raw response and/or an error in case something went wrong or the server is not running a team mode (ErrNotTeamMode).
 func (s *Server) GetTeamScoresRaw() (teamScoresRaw TeamScoresRaw, err error) {
-	request := buildRequest(InfoTypeExtended, ExtInfoTypeTeamScores, 0)
-	response, err := s.queryServer(request)
-	if err != nil {
+	err = s.queryServer(buildRequest(InfoTypeExtended, ExtInfoTypeTeamScores, 0), func(response *cubecode.Packet) (err error) {
+		teamScoresRaw, err = s.parseTeamScores(response)
 		return
-	}
+	})
+	return
+}
 
+// parses the response to a team scores request, starting after the error byte
+func (s *Server) parseTeamScores(response *cubecode.Packet) (teamScoresRaw TeamScoresRaw, err error) {
 	teamScoresRaw.GameMode, err = response.ReadInt()
 	if err != nil {
 		return
@@ -38,23 +49,35 @@ func (s *Server) GetTeamScoresRaw() (teamScoresRaw TeamScoresRaw, err error) {
 		return
 	}
 
+	// the number of teams is not sent, so the teams make up the rest of the response, unless a parser for the server's mod knows better
+	teams, extension, err := s.parseTeamScoresExtension(response)
+	if err != nil {
+		return
+	}
+	teamScoresRaw.Extension = extension
+
 	teamScoresRaw.Scores = map[string]TeamScore{}
 
-	for response.HasRemaining() {
+	for teams.HasRemaining() {
 		var name string
-		name, err = response.ReadString()
+		name, err = readString(teams, "")
 		if err != nil {
 			return
 		}
 
+		if len(teamScoresRaw.Scores) == MaxTeams {
+			err = errors.New("extinfo: invalid response: more than " + strconv.Itoa(MaxTeams) + " teams")
+			return
+		}
+
 		var score int
-		score, err = response.ReadInt()
+		score, err = teams.ReadInt()
 		if err != nil {
 			return
 		}
 
 		var numBases int
-		numBases, err = response.ReadInt()
+		numBases, err = teams.ReadInt()
 		if err != nil {
 			return
 		}
@@ -63,11 +86,17 @@ func (s *Server) GetTeamScoresRaw() (teamScoresRaw TeamScoresRaw, err error) {
 			numBases = 0
 		}
 
-		bases := make([]int, numBases)
+		// every base takes at least one byte
+		if numBases > MaxBases || numBases > teams.Len() {
+			err = errors.New("extinfo: invalid response: team " + name + " has " + strconv.Itoa(numBases) + " bases")
+			return
+		}
+
+		bases := make([]int, 0, numBases)
 
 		for i := 0; i < numBases; i++ {
 			var base int
-			base, err = response.ReadInt()
+			base, err = teams.ReadInt()
 			if err != nil {
 				return
 			}
@@ -0,0 +1,67 @@
+package extinfo
+
+import (
+	"net"
+	"time"
+)
+
+// Transport sends requests to servers and receives the datagrams they respond with. Servers use DefaultTransport unless created with UseTransport(). NewRecorder() and NewReplay() return transports that capture exchanges with real servers and play them back, e.g. in tests.
+type Transport interface {
+	// Open sends request to addr (the server's info port) and returns a Conn to read the response from.
+	Open(addr *net.UDPAddr, request []byte) (Conn, error)
+}
+
+// Conn receives the datagrams a server sends in response to one request.
+type Conn interface {
+	// Read reads the next datagram into buf and returns its length. If no datagram arrives within timeOut, it returns an error implementing net.Error whose Timeout() method returns true.
+	Read(buf []byte, timeOut time.Duration) (int, error)
+
+	// Close releases the resources used to receive the response.
+	Close() error
+}
+
+// DefaultTransport sends requests to servers using UDP.
+var DefaultTransport Transport = udpTransport{}
+
+type udpTransport struct{}
+
+func (udpTransport) Open(addr *net.UDPAddr, request []byte) (Conn, error) {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Write(request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return udpConn{conn}, nil
+}
+
+type udpConn struct {
+	*net.UDPConn
+}
+
+func (c udpConn) Read(buf []byte, timeOut time.Duration) (int, error) {
+	c.SetReadDeadline(time.Now().Add(timeOut))
+	return c.UDPConn.Read(buf)
+}
+
+// ServerOption changes how a Server created by NewServer() queries the game server.
+type ServerOption func(*Server)
+
+// UseTransport makes the Server send its requests using t instead of DefaultTransport.
+func UseTransport(t Transport) ServerOption {
+	return func(s *Server) {
+		s.transport = t
+	}
+}
+
+// Deadline makes the Server stop waiting for responses at deadline, even if its time out didn't pass yet, so several queries together take no longer than that. Queries made after the deadline fail with a time out error.
+func Deadline(deadline time.Time) ServerOption {
+	return func(s *Server) {
+		s.deadline = deadline
+	}
+}
@@ -4,13 +4,13 @@ import "github.com/sauerbraten/cubecode"
 
 // GetUptime returns the uptime of the server in seconds.
 func (s *Server) GetUptime() (uptime int, err error) {
-	var response *cubecode.Packet
-	response, err = s.queryServer(buildRequest(InfoTypeExtended, ExtInfoTypeUptime, 0))
-	if err != nil {
+	err = s.queryServer(buildExtendedRequest(ExtInfoTypeUptime), func(response *cubecode.Packet) (err error) {
+		uptime, err = response.ReadInt()
 		return
+	})
+	if err == nil {
+		s.observeUptime(uptime)
 	}
 
-	uptime, err = response.ReadInt()
-
 	return
 }
@@ -0,0 +1,307 @@
+// Package uptime tracks when servers were up, using the uptime they report and whether they respond: it detects restarts, records outages and computes availability over any time window, e.g. to publish uptime SLAs.
+//
+// Feed a Monitor with poll results, all of them, since a missed update could be the one showing an outage:
+//
+//	monitor := uptime.New()
+//	updates, unsubscribe := poller.Subscribe(poll.Lossless())
+//	defer unsubscribe()
+//	go monitor.Follow(ctx, updates)
+//	...
+//	report, ok := monitor.Report("sauerleague.org:10000", time.Now().AddDate(0, 0, -30), time.Now())
+//
+// A Monitor only knows what it observed itself. To compute availability over a longer history, replay an archive into it using Observe().
+package uptime
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+// Restart is a restart of a server, detected by its uptime going backwards.
+type Restart struct {
+	Addr          string    `json:"addr"`          // the server's address as host:port
+	Start         time.Time `json:"start"`         // when the new process was started, estimated from its uptime
+	PreviousStart time.Time `json:"previousStart"` // when the previous process was started, estimated from its uptime
+	LastSeen      time.Time `json:"lastSeen"`      // when the previous process last responded
+}
+
+// Outage is a period of time a server did not respond.
+type Outage struct {
+	Addr      string    `json:"addr"`            // the server's address as host:port
+	Start     time.Time `json:"start"`           // when the server first did not respond, or, for a restart between two polls, when the previous process last responded
+	End       time.Time `json:"end"`             // when the server responded again (or, if restarted, when the new process started); zero while the outage lasts
+	Restarted bool      `json:"restarted"`       // whether the server came back as new process
+	Error     string    `json:"error,omitempty"` // why the first query during the outage failed
+}
+
+// duration returns how much of the outage falls between from and to
+func (o Outage) duration(from, to time.Time) time.Duration {
+	start, end := o.Start, o.End
+	if end.IsZero() || end.After(to) {
+		end = to
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Option changes how a Monitor detects restarts and how long it keeps its history.
+type Option func(*options)
+
+type options struct {
+	tolerance time.Duration
+	retention time.Duration
+}
+
+// RestartTolerance sets how far the estimated start of a server's process may move before it's considered restarted. The estimate moves by up to a second between polls, since servers report their uptime in seconds, plus the network latency; it also moves when the server is so busy it doesn't keep its time. The default is 30 seconds. Restarts are always detected if the uptime is lower than in the previous poll.
+func RestartTolerance(d time.Duration) Option {
+	return func(o *options) {
+		o.tolerance = d
+	}
+}
+
+// Retention sets how long outages and restarts are kept. The default is 90 days.
+func Retention(d time.Duration) Option {
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// Monitor tracks the uptime of servers. It is safe for concurrent use.
+type Monitor struct {
+	options options
+
+	mutex   sync.Mutex
+	servers map[string]*server
+}
+
+// what the monitor knows about one server
+type server struct {
+	firstPoll time.Time // when the server was first observed, whether it responded or not
+	lastPoll  time.Time
+	start     time.Time // estimated start of the current process, zero before the first response
+	uptime    int       // the uptime of the last response
+	lastSeen  time.Time // the time of the last response
+	outage    *Outage   // the current outage, if the server is not responding
+	outages   []Outage  // past outages, oldest first
+	restarts  []Restart // oldest first
+}
+
+// New returns a Monitor without any observations.
+func New(opts ...Option) *Monitor {
+	o := options{
+		tolerance: 30 * time.Second,
+		retention: 90 * 24 * time.Hour,
+	}
+	for _, option := range opts {
+		option(&o)
+	}
+
+	return &Monitor{
+		options: o,
+		servers: map[string]*server{},
+	}
+}
+
+// Observe records the result of querying the uptime of the server at addr at time t: either the uptime in seconds, or the error that occured. Observations of a server have to be passed in chronological order; older ones are ignored.
+// If the observation revealed a restart, it is returned.
+func (m *Monitor) Observe(addr string, t time.Time, uptime int, err error) *Restart {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.servers[addr]
+	if !ok {
+		s = &server{firstPoll: t}
+		m.servers[addr] = s
+	}
+	if t.Before(s.lastPoll) {
+		return nil
+	}
+	s.lastPoll = t
+	defer m.prune(s, t)
+
+	if err != nil {
+		if s.outage == nil {
+			s.outage = &Outage{Addr: addr, Start: t, Error: err.Error()}
+		}
+		return nil
+	}
+
+	start := t.Add(-time.Duration(uptime) * time.Second)
+
+	var restart *Restart
+	if !s.start.IsZero() && (uptime < s.uptime || start.Sub(s.start) > m.options.tolerance) {
+		restart = &Restart{Addr: addr, Start: start, PreviousStart: s.start, LastSeen: s.lastSeen}
+		s.restarts = append(s.restarts, *restart)
+
+		// restarted between two polls: the server was down since it was last seen, at most
+		if s.outage == nil && start.After(s.lastSeen) {
+			s.outage = &Outage{Addr: addr, Start: s.lastSeen}
+		}
+	}
+
+	if s.outage != nil {
+		s.outage.End = t
+		if restart != nil {
+			s.outage.Restarted = true
+			// the new process was up before we noticed
+			if start.After(s.outage.Start) && start.Before(t) {
+				s.outage.End = start
+			}
+		}
+		s.outages = append(s.outages, *s.outage)
+		s.outage = nil
+	}
+
+	// keep the earliest estimate, the later ones are off by the latency of the responses
+	if restart != nil || s.start.IsZero() || start.Before(s.start) {
+		s.start = start
+	}
+	s.uptime = uptime
+	s.lastSeen = t
+
+	return restart
+}
+
+// drops outages and restarts older than the retention period
+func (m *Monitor) prune(s *server, now time.Time) {
+	cutoff := now.Add(-m.options.retention)
+
+	i := 0
+	for i < len(s.outages) && s.outages[i].End.Before(cutoff) {
+		i++
+	}
+	s.outages = s.outages[i:]
+
+	i = 0
+	for i < len(s.restarts) && s.restarts[i].Start.Before(cutoff) {
+		i++
+	}
+	s.restarts = s.restarts[i:]
+
+	if s.firstPoll.Before(cutoff) {
+		s.firstPoll = cutoff
+	}
+}
+
+// Record observes the result of a poll.
+func (m *Monitor) Record(update poll.Update) *Restart {
+	state := update.State
+	if state.LastError != "" {
+		return m.Observe(state.Addr, state.LastPoll, 0, errors.New(state.LastError))
+	}
+	if state.Snapshot == nil {
+		return nil
+	}
+	return m.Observe(state.Addr, state.LastPoll, state.Snapshot.Uptime, nil)
+}
+
+// Follow records every update received from updates (see (*poll.Poller).Subscribe(), using poll.Lossless() to measure outages correctly), until ctx is cancelled or updates is closed.
+func (m *Monitor) Follow(ctx context.Context, updates <-chan poll.Update) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			m.Record(update)
+		}
+	}
+}
+
+// Servers returns the addresses of all servers observed, sorted.
+func (m *Monitor) Servers() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	addrs := make([]string, 0, len(m.servers))
+	for addr := range m.servers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Start returns when the process currently running the server at addr was started, estimated from its uptime. ok is false if the server never responded.
+func (m *Monitor) Start(addr string) (start time.Time, ok bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.servers[addr]
+	if !ok || s.start.IsZero() {
+		return time.Time{}, false
+	}
+	return s.start, true
+}
+
+// Report summarizes the availability of a server during a period of time.
+type Report struct {
+	Addr         string        `json:"addr"`         // the server's address as host:port
+	From         time.Time     `json:"from"`         // the start of the period covered, which is later than requested if the server was observed for the first time later
+	To           time.Time     `json:"to"`           // the end of the period covered, which is earlier than requested if the server was not observed since
+	Downtime     time.Duration `json:"downtime"`     // how long the server did not respond
+	Availability float64       `json:"availability"` // the percentage of time the server responded
+	Outages      []Outage      `json:"outages"`      // the outages overlapping the period, oldest first
+	Restarts     []Restart     `json:"restarts"`     // the restarts during the period, oldest first
+}
+
+// Report returns a report on the availability of the server at addr between from and to. ok is false if the server was not observed during that time.
+func (m *Monitor) Report(addr string, from, to time.Time) (report Report, ok bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.servers[addr]
+	if !ok {
+		return
+	}
+
+	if from.Before(s.firstPoll) {
+		from = s.firstPoll
+	}
+	if to.After(s.lastPoll) {
+		to = s.lastPoll
+	}
+	if !from.Before(to) {
+		return report, false
+	}
+
+	report = Report{
+		Addr:     addr,
+		From:     from,
+		To:       to,
+		Outages:  []Outage{},
+		Restarts: []Restart{},
+	}
+
+	outages := s.outages
+	if s.outage != nil {
+		outages = append(outages[:len(outages):len(outages)], *s.outage)
+	}
+	for _, outage := range outages {
+		if d := outage.duration(from, to); d > 0 {
+			report.Downtime += d
+			report.Outages = append(report.Outages, outage)
+		}
+	}
+
+	for _, restart := range s.restarts {
+		if !restart.Start.Before(from) && !restart.Start.After(to) {
+			report.Restarts = append(report.Restarts, restart)
+		}
+	}
+
+	report.Availability = 100 * (1 - float64(report.Downtime)/float64(to.Sub(from)))
+
+	return report, true
+}
@@ -0,0 +1,155 @@
+package uptime
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sauerbraten/extinfo/poll"
+)
+
+const addr = "127.0.0.1:28785"
+
+var (
+	t0      = time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+	timeout = errors.New("i/o timeout")
+)
+
+// returns the time s seconds after t0
+func at(s int) time.Time {
+	return t0.Add(time.Duration(s) * time.Second)
+}
+
+func TestMonitor(t *testing.T) {
+	m := New()
+
+	// the server was started an hour before the first poll
+	observations := []struct {
+		time    int
+		uptime  int
+		err     error
+		restart bool
+	}{
+		{0, 3600, nil, false},
+		{30, 3630, nil, false},
+		{60, 0, timeout, false},
+		{90, 0, timeout, false},
+		{120, 3720, nil, false}, // just packet loss, no restart
+		{150, 5, nil, true},     // restarted between two polls
+		{180, 0, timeout, false},
+		{210, 0, timeout, false},
+		{240, 20, nil, true}, // crashed and restarted
+		{270, 50, nil, false},
+	}
+
+	for _, o := range observations {
+		restart := m.Observe(addr, at(o.time), o.uptime, o.err)
+		if (restart != nil) != o.restart {
+			t.Errorf("%ds: expected restart: %v, got %+v", o.time, o.restart, restart)
+		}
+	}
+
+	start, ok := m.Start(addr)
+	if !ok || !start.Equal(at(220)) {
+		t.Errorf("expected start at %v, got %v", at(220), start)
+	}
+
+	report, ok := m.Report(addr, at(-1000), at(1000))
+	if !ok {
+		t.Fatal("no report")
+	}
+	if !report.From.Equal(at(0)) || !report.To.Equal(at(270)) {
+		t.Errorf("expected report to be clamped to observed period, got %v to %v", report.From, report.To)
+	}
+
+	expectedOutages := []Outage{
+		{Addr: addr, Start: at(60), End: at(120), Error: "i/o timeout"},
+		{Addr: addr, Start: at(120), End: at(145), Restarted: true},
+		{Addr: addr, Start: at(180), End: at(220), Restarted: true, Error: "i/o timeout"},
+	}
+	if len(report.Outages) != len(expectedOutages) {
+		t.Fatalf("expected outages %+v, got %+v", expectedOutages, report.Outages)
+	}
+	for i, outage := range report.Outages {
+		if outage != expectedOutages[i] {
+			t.Errorf("expected outage %+v, got %+v", expectedOutages[i], outage)
+		}
+	}
+
+	if len(report.Restarts) != 2 || !report.Restarts[0].PreviousStart.Equal(at(-3600)) || !report.Restarts[1].LastSeen.Equal(at(150)) {
+		t.Errorf("unexpected restarts %+v", report.Restarts)
+	}
+
+	if report.Downtime != 125*time.Second {
+		t.Errorf("expected 125s of downtime, got %v", report.Downtime)
+	}
+	if expected := 100 * (1 - 125.0/270.0); math.Abs(report.Availability-expected) > 1e-9 {
+		t.Errorf("expected availability %f, got %f", expected, report.Availability)
+	}
+
+	// only the part of the outage in the window counts
+	report, _ = m.Report(addr, at(90), at(150))
+	if report.Downtime != 55*time.Second || len(report.Outages) != 2 || len(report.Restarts) != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if _, ok := m.Report("127.0.0.1:10000", at(0), at(100)); ok {
+		t.Error("expected no report for unknown server")
+	}
+}
+
+func TestOngoingOutage(t *testing.T) {
+	m := New()
+	m.Observe(addr, at(0), 100, nil)
+	m.Observe(addr, at(60), 0, timeout)
+	m.Observe(addr, at(120), 0, timeout)
+
+	report, ok := m.Report(addr, at(0), at(120))
+	if !ok || report.Downtime != time.Minute || report.Availability != 50 || len(report.Outages) != 1 || !report.Outages[0].End.IsZero() {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestRestartTolerance(t *testing.T) {
+	m := New(RestartTolerance(5 * time.Second))
+	m.Observe(addr, at(0), 100, nil)
+
+	// the server lagged behind by 3 seconds
+	if restart := m.Observe(addr, at(60), 157, nil); restart != nil {
+		t.Errorf("unexpected restart %+v", restart)
+	}
+
+	// 20 seconds is too much to be lag, the server was restarted before its uptime could go backwards
+	if restart := m.Observe(addr, at(120000), 120000+80, nil); restart == nil {
+		t.Error("restart not detected")
+	}
+}
+
+func TestRetention(t *testing.T) {
+	m := New(Retention(time.Hour))
+	m.Observe(addr, at(0), 0, timeout)
+	m.Observe(addr, at(60), 100, nil)
+	m.Observe(addr, at(7200), 7300, nil)
+
+	report, ok := m.Report(addr, at(0), at(7200))
+	if !ok || !report.From.Equal(at(3600)) || len(report.Outages) != 0 || report.Availability != 100 {
+		t.Errorf("expected old outage to be dropped, got %+v", report)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	m := New()
+	m.Record(poll.Update{State: poll.State{Addr: addr, LastPoll: at(0), Snapshot: &poll.Snapshot{Uptime: 100}}})
+	m.Record(poll.Update{State: poll.State{Addr: addr, LastPoll: at(30), LastError: "i/o timeout"}})
+	m.Record(poll.Update{State: poll.State{Addr: addr, LastPoll: at(60), Snapshot: &poll.Snapshot{Uptime: 10}}})
+
+	report, ok := m.Report(addr, at(0), at(60))
+	if !ok || len(report.Restarts) != 1 || len(report.Outages) != 1 || report.Outages[0].Error != "i/o timeout" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if servers := m.Servers(); len(servers) != 1 || servers[0] != addr {
+		t.Errorf("unexpected servers %v", servers)
+	}
+}